@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"fmt"
+	"mime"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// capturedRequest is a request recorded by a browser or copied as a curl
+// command. Status is 0 when no response was captured.
+type capturedRequest struct {
+	Method  string
+	URL     string
+	Headers [][2]string
+	Body    string
+	Status  int
+}
+
+// captureImporter turns captured requests into scenarios. Origins become
+// {{base_url}} data entries and secrets found in headers become data entries
+// too, so that the scenario files can be shared.
+type captureImporter struct {
+	report  *migrationReport
+	data    map[string]string
+	origins map[string]string
+	secrets map[string]string
+	names   map[string]int
+	files   map[string][]scenarioSpec
+}
+
+func newCaptureImporter(title string) *captureImporter {
+	return &captureImporter{
+		report:  &migrationReport{title: title},
+		data:    map[string]string{},
+		origins: map[string]string{},
+		secrets: map[string]string{},
+		names:   map[string]int{},
+		files:   map[string][]scenarioSpec{},
+	}
+}
+
+// droppedHeaders are set by the browser or the http client itself.
+var droppedHeaders = map[string]bool{
+	"host":                      true,
+	"content-length":            true,
+	"connection":                true,
+	"keep-alive":                true,
+	"accept-encoding":           true,
+	"transfer-encoding":         true,
+	"te":                        true,
+	"priority":                  true,
+	"upgrade-insecure-requests": true,
+}
+
+var (
+	secretHeader = regexp.MustCompile(`(?i)^(authorization|proxy-authorization|cookie)$|token|secret|api-?key|password|session`)
+	authScheme   = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9-]*) +(\S.*)$`)
+)
+
+// add turns a request into a scenario, filed under its host.
+func (importer *captureImporter) add(request capturedRequest) {
+	where := request.Method + " " + request.URL
+	parsed, err := url.Parse(request.URL)
+	if err != nil || parsed.Host == "" {
+		importer.report.add(where, "the url cannot be parsed, the request is skipped")
+		return
+	}
+	spec := scenarioSpec{
+		Scenario: importer.name(request.Method + " " + parsed.Path),
+		Service:  parsed.Hostname(),
+		Method:   request.Method,
+		Url:      "{{" + importer.origin(parsed.Scheme+"://"+parsed.Host) + "}}" + parsed.EscapedPath(),
+		Status:   request.Status,
+	}
+	if spec.Status == 0 {
+		spec.Status = 200
+	}
+	query, err := url.ParseQuery(parsed.RawQuery)
+	if err != nil {
+		importer.report.add(where, "the query cannot be parsed: %v", err)
+	}
+	for key, values := range query {
+		if spec.Params == nil {
+			spec.Params = map[string]string{}
+		}
+		if len(values) > 1 {
+			importer.report.add(where, "query parameter `%s` is repeated, only the last value is kept", key)
+		}
+		spec.Params[key] = values[len(values)-1]
+	}
+
+	var contentType string
+	for _, header := range request.Headers {
+		name, value := header[0], header[1]
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(name, ":") || strings.HasPrefix(lower, "sec-") || droppedHeaders[lower] {
+			continue
+		}
+		if lower == "content-type" {
+			contentType = value
+		}
+		if spec.Headers == nil {
+			spec.Headers = map[string]string{}
+		}
+		spec.Headers[name] = importer.secret(name, value)
+	}
+
+	if request.Body != "" {
+		mediaType, _, _ := mime.ParseMediaType(contentType)
+		switch {
+		case mediaType == "multipart/form-data":
+			importer.report.add(where, "multipart bodies are not supported, the body is left out")
+		case mediaType == "application/x-www-form-urlencoded":
+			spec.Tag = "urlencoded"
+			spec.Body = request.Body
+		default:
+			spec.Tag = "plain"
+			spec.Body = request.Body
+		}
+	}
+	importer.files[spec.Service] = append(importer.files[spec.Service], spec)
+}
+
+// name makes the scenario names unique, since captures often repeat requests.
+func (importer *captureImporter) name(name string) string {
+	importer.names[name]++
+	if n := importer.names[name]; n > 1 {
+		return fmt.Sprintf("%s #%d", name, n)
+	}
+	return name
+}
+
+// origin returns the data entry holding a scheme and host: base_url for the
+// first one, base_url_2 and so on for the next.
+func (importer *captureImporter) origin(origin string) string {
+	if key, ok := importer.origins[origin]; ok {
+		return key
+	}
+	key := "base_url"
+	if n := len(importer.origins); n > 0 {
+		key = fmt.Sprintf("base_url_%d", n+1)
+	}
+	importer.origins[origin] = key
+	importer.data[key] = origin
+	return key
+}
+
+// secret moves the value of a credential header into a data entry and
+// returns the header value with a placeholder. The auth scheme of an
+// Authorization header is kept in the scenario.
+func (importer *captureImporter) secret(name, value string) string {
+	if !secretHeader.MatchString(name) || value == "" {
+		return value
+	}
+	prefix, secret := "", value
+	key := strings.Trim(unsafeName.ReplaceAllString(strings.ToLower(name), "_"), "_.")
+	key = strings.ReplaceAll(key, "-", "_")
+	if strings.HasSuffix(strings.ToLower(name), "authorization") {
+		if match := authScheme.FindStringSubmatch(value); match != nil {
+			prefix, secret = match[1]+" ", match[2]
+			switch strings.ToLower(match[1]) {
+			case "bearer":
+				key = "token"
+			case "basic":
+				key = "basic_auth"
+			}
+		}
+	}
+	if existing, ok := importer.secrets[secret]; ok {
+		return prefix + "{{" + existing + "}}"
+	}
+	base := key
+	for n := 2; importer.data[key] != ""; n++ {
+		key = fmt.Sprintf("%s_%d", base, n)
+	}
+	importer.secrets[secret] = key
+	importer.data[key] = secret
+	importer.report.add(name, "the captured value was moved to data.%s, keep configs.yaml out of version control or replace it", key)
+	return prefix + "{{" + key + "}}"
+}
+
+// write saves the scenarios, one file per host, and the config.
+func (importer *captureImporter) write(dir string, config configSpec, reportName string) error {
+	hosts := make([]string, 0, len(importer.files))
+	for host := range importer.files {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	count := 0
+	for _, host := range hosts {
+		config.Services = append(config.Services, serviceSpec{Name: host})
+		if err := writeYAML(filepath.Join(dir, "scenarios", fileName(host)+".yaml"), importer.files[host]); err != nil {
+			return err
+		}
+		count += len(importer.files[host])
+	}
+	if len(importer.data) > 0 {
+		config.Data = importer.data
+	}
+	if err := writeYAML(filepath.Join(dir, "configs.yaml"), config); err != nil {
+		return err
+	}
+	if err := importer.report.write(filepath.Join(dir, reportName)); err != nil {
+		return err
+	}
+	fmt.Printf("Imported %d requests into %d scenario files under %s, %d items need a manual look (see %s).\n",
+		count, len(hosts), dir, len(importer.report.entries), filepath.Join(dir, reportName))
+	fmt.Printf("Run them with: dash -c %s -s %s\n", filepath.Join(dir, "configs.yaml"), filepath.Join(dir, "scenarios"))
+	return nil
+}
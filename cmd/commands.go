@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	app "github.com/derrick-gopher/dash/utils"
+)
+
+// Execute runs a dash subcommand such as "import postman" and returns the
+// process exit code.
+func Execute(args []string) int {
+	var err error
+	switch {
+	case len(args) >= 2 && args[0] == "import" && args[1] == "postman":
+		err = importPostman(args[2:])
+	case len(args) >= 2 && args[0] == "import" && args[1] == "har":
+		err = importHAR(args[2:])
+	case len(args) >= 2 && args[0] == "import" && args[1] == "curl":
+		err = importCurl(args[2:])
+	case args[0] == "curl":
+		err = printCurl(args[1:])
+	case len(args) >= 2 && args[0] == "generate" && args[1] == "openapi":
+		err = generateOpenAPI(args[2:])
+	default:
+		fmt.Fprintln(os.Stderr, "usage:")
+		fmt.Fprintln(os.Stderr, "  dash -c configs.yaml -s scenarios [options]")
+		fmt.Fprintln(os.Stderr, "  dash import postman [-out dir] <collection.json>")
+		fmt.Fprintln(os.Stderr, "  dash import har [-out dir] [-all] [-include regex] <file.har>")
+		fmt.Fprintln(os.Stderr, "  dash import curl [-out dir] [file with curl commands]")
+		fmt.Fprintln(os.Stderr, "  dash curl -c configs.yaml -s scenarios <scenario name>")
+		fmt.Fprintln(os.Stderr, "  dash generate openapi [-out dir] <spec.yaml>")
+		return app.ExitConfig
+	}
+	if err == flag.ErrHelp {
+		return app.ExitPassed
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return app.ExitConfig
+	}
+	return app.ExitPassed
+}
@@ -0,0 +1,370 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// curlValueOptions are the curl options that take a value. The ones not
+// handled in curlRequest are reported.
+var curlValueOptions = map[string]bool{
+	"-X": true, "--request": true, "-H": true, "--header": true, "-d": true, "--data": true, "--data-raw": true,
+	"--data-binary": true, "--data-ascii": true, "--data-urlencode": true, "--json": true, "-u": true, "--user": true,
+	"-b": true, "--cookie": true, "-A": true, "--user-agent": true, "-e": true, "--referer": true, "--url": true,
+	"-F": true, "--form": true, "-o": true, "--output": true, "-m": true, "--max-time": true, "--connect-timeout": true,
+	"-x": true, "--proxy": true, "-w": true, "--write-out": true, "--retry": true, "-c": true, "--cookie-jar": true,
+}
+
+// curlIgnoredOptions change how curl prints or connects, not the request.
+var curlIgnoredOptions = map[string]bool{
+	"-s": true, "--silent": true, "-S": true, "--show-error": true, "-v": true, "--verbose": true, "-i": true,
+	"--include": true, "-L": true, "--location": true, "--compressed": true, "-f": true, "--fail": true,
+	"-o": true, "--output": true, "-w": true, "--write-out": true, "--http1.1": true, "--http2": true,
+	"-c": true, "--cookie-jar": true,
+}
+
+func importCurl(args []string) error {
+	flags := flag.NewFlagSet("import curl", flag.ContinueOnError)
+	out := flags.String("out", "curl", "directory to write configs.yaml and the scenarios to")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 1 {
+		return fmt.Errorf("usage: dash import curl [-out dir] [file with curl commands, stdin by default]")
+	}
+	var (
+		data []byte
+		err  error
+	)
+	if flags.NArg() == 0 || flags.Arg(0) == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(flags.Arg(0))
+	}
+	if err != nil {
+		return err
+	}
+	words, err := shellWords(string(data))
+	if err != nil {
+		return err
+	}
+	importer := newCaptureImporter("Import report for curl commands")
+	commands := 0
+	for _, command := range curlCommands(words) {
+		request, ok := importer.curlRequest(command)
+		if ok {
+			importer.add(request)
+			commands++
+		}
+	}
+	if commands == 0 {
+		return fmt.Errorf("no curl command found")
+	}
+	return importer.write(*out, configSpec{}, "import-report.md")
+}
+
+// curlCommands splits the words into commands: each one starts with curl
+// and ends at ;, && or the next curl.
+func curlCommands(words []string) [][]string {
+	var commands [][]string
+	var current []string
+	for _, word := range words {
+		switch {
+		case word == "curl" || strings.HasSuffix(word, "/curl") || word == "curl.exe":
+			if current != nil {
+				commands = append(commands, current)
+			}
+			current = []string{}
+		case word == ";" || word == "&&" || word == "||" || word == "|":
+			if current != nil {
+				commands = append(commands, current)
+			}
+			current = nil
+		case current != nil:
+			current = append(current, word)
+		}
+	}
+	if current != nil {
+		commands = append(commands, current)
+	}
+	return commands
+}
+
+// curlRequest reads the options of a curl command into a request.
+func (importer *captureImporter) curlRequest(args []string) (capturedRequest, bool) {
+	var (
+		request capturedRequest
+		data    []string
+		get     bool
+		head    bool
+	)
+	where := "curl " + strings.Join(args, " ")
+	if len(where) > 80 {
+		where = where[:77] + "..."
+	}
+	args = splitShortOptions(args)
+	for i := 0; i < len(args); i++ {
+		option, value := args[i], ""
+		if !strings.HasPrefix(option, "-") {
+			request.URL = option
+			continue
+		}
+		if strings.HasPrefix(option, "--") {
+			if j := strings.Index(option, "="); j > 0 {
+				option, value = option[:j], option[j+1:]
+			} else if curlValueOptions[option] && i+1 < len(args) {
+				i++
+				value = args[i]
+			}
+		} else if curlValueOptions[option] && i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+		switch option {
+		case "-X", "--request":
+			request.Method = strings.ToUpper(value)
+		case "--url":
+			request.URL = value
+		case "-H", "--header":
+			parts := strings.SplitN(value, ":", 2)
+			if len(parts) == 2 {
+				request.Headers = append(request.Headers, [2]string{strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])})
+			}
+		case "-A", "--user-agent":
+			request.Headers = append(request.Headers, [2]string{"User-Agent", value})
+		case "-e", "--referer":
+			request.Headers = append(request.Headers, [2]string{"Referer", value})
+		case "-b", "--cookie":
+			if strings.Contains(value, "=") {
+				request.Headers = append(request.Headers, [2]string{"Cookie", value})
+			} else {
+				importer.report.add(where, "cookie file `%s` is not read", value)
+			}
+		case "-u", "--user":
+			request.Headers = append(request.Headers, [2]string{"Authorization", "Basic " + base64.StdEncoding.EncodeToString([]byte(value))})
+		case "-d", "--data", "--data-raw", "--data-binary", "--data-ascii":
+			if strings.HasPrefix(value, "@") && option != "--data-raw" {
+				importer.report.add(where, "body file `%s` is not read", value[1:])
+				continue
+			}
+			data = append(data, value)
+		case "--data-urlencode":
+			if j := strings.Index(value, "="); j >= 0 {
+				data = append(data, value[:j+1]+url.QueryEscape(value[j+1:]))
+			} else {
+				data = append(data, url.QueryEscape(value))
+			}
+		case "--json":
+			data = append(data, value)
+			if !hasHeader(request.Headers, "Content-Type") {
+				request.Headers = append(request.Headers, [2]string{"Content-Type", "application/json"})
+			}
+		case "-F", "--form":
+			importer.report.add(where, "multipart form field `%s` is not supported", value)
+		case "-G", "--get":
+			get = true
+		case "-I", "--head":
+			head = true
+		default:
+			if !curlIgnoredOptions[option] {
+				importer.report.add(where, "option `%s` is not translated", option)
+			}
+		}
+	}
+	if request.URL == "" {
+		importer.report.add(where, "the command has no url, it is skipped")
+		return request, false
+	}
+	if !strings.Contains(request.URL, "://") {
+		request.URL = "http://" + request.URL
+	}
+	body := strings.Join(data, "&")
+	switch {
+	case get && body != "":
+		separator := "?"
+		if strings.Contains(request.URL, "?") {
+			separator = "&"
+		}
+		request.URL += separator + body
+	case body != "":
+		request.Body = body
+		if !hasHeader(request.Headers, "Content-Type") {
+			request.Headers = append(request.Headers, [2]string{"Content-Type", "application/x-www-form-urlencoded"})
+		}
+	}
+	if request.Method == "" {
+		switch {
+		case head:
+			request.Method = "HEAD"
+		case request.Body != "":
+			request.Method = "POST"
+		default:
+			request.Method = "GET"
+		}
+	}
+	return request, true
+}
+
+// splitShortOptions splits combined short options the way curl reads them:
+// -sSLXPOST is -s -S -L -X POST. The letters after one that takes a value
+// are that value.
+func splitShortOptions(args []string) []string {
+	var out []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || strings.HasPrefix(arg, "--") || len(arg) <= 2 {
+			out = append(out, arg)
+			if curlValueOptions[arg] && i+1 < len(args) {
+				i++
+				out = append(out, args[i])
+			}
+			continue
+		}
+		for j := 1; j < len(arg); j++ {
+			short := "-" + arg[j:j+1]
+			out = append(out, short)
+			if !curlValueOptions[short] {
+				continue
+			}
+			if j+1 < len(arg) {
+				out = append(out, arg[j+1:])
+			} else if i+1 < len(args) {
+				i++
+				out = append(out, args[i])
+			}
+			break
+		}
+	}
+	return out
+}
+
+// shellWords splits a shell command line as bash would for the quoting used
+// by browsers when they copy a request as curl: single and double quotes,
+// $'...' strings, backslash escapes and line continuations.
+func shellWords(input string) ([]string, error) {
+	var (
+		words  []string
+		word   strings.Builder
+		inWord bool
+	)
+	flush := func() {
+		if inWord {
+			words = append(words, word.String())
+			word.Reset()
+			inWord = false
+		}
+	}
+	for i := 0; i < len(input); i++ {
+		c := input[i]
+		switch {
+		case c == '\\' && i+1 < len(input) && (input[i+1] == '\n' || input[i+1] == '\r'):
+			i++
+			if input[i] == '\r' && i+1 < len(input) && input[i+1] == '\n' {
+				i++
+			}
+		case c == '\\' && i+1 < len(input):
+			i++
+			word.WriteByte(input[i])
+			inWord = true
+		case c == '\'':
+			end := strings.IndexByte(input[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote")
+			}
+			word.WriteString(input[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case c == '$' && i+1 < len(input) && input[i+1] == '\'':
+			value, n, err := ansiQuoted(input[i+2:])
+			if err != nil {
+				return nil, err
+			}
+			word.WriteString(value)
+			i += n + 2
+			inWord = true
+		case c == '"':
+			i++
+			for ; i < len(input) && input[i] != '"'; i++ {
+				if input[i] == '\\' && i+1 < len(input) && strings.IndexByte("\"\\$`\n", input[i+1]) >= 0 {
+					i++
+					if input[i] == '\n' {
+						continue
+					}
+				}
+				word.WriteByte(input[i])
+			}
+			if i >= len(input) {
+				return nil, fmt.Errorf("unterminated double quote")
+			}
+			inWord = true
+		case c == ';' || c == '|' || c == '&':
+			flush()
+			operator := string(c)
+			if i+1 < len(input) && input[i+1] == c && c != ';' {
+				operator += string(c)
+				i++
+			}
+			words = append(words, operator)
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			flush()
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	flush()
+	return words, nil
+}
+
+// ansiQuoted reads a $'...' string up to its closing quote and returns the
+// value and the number of bytes read, the quote included.
+func ansiQuoted(input string) (string, int, error) {
+	var out strings.Builder
+	for i := 0; i < len(input); i++ {
+		c := input[i]
+		if c == '\'' {
+			return out.String(), i + 1, nil
+		}
+		if c != '\\' || i+1 >= len(input) {
+			out.WriteByte(c)
+			continue
+		}
+		i++
+		switch input[i] {
+		case 'n':
+			out.WriteByte('\n')
+		case 't':
+			out.WriteByte('\t')
+		case 'r':
+			out.WriteByte('\r')
+		case 'u':
+			var r rune
+			if i+4 < len(input) {
+				if _, err := fmt.Sscanf(input[i+1:i+5], "%04x", &r); err == nil {
+					out.WriteRune(r)
+					i += 4
+					continue
+				}
+			}
+			out.WriteString(`\u`)
+		case 'x':
+			var b byte
+			if i+2 < len(input) {
+				if _, err := fmt.Sscanf(input[i+1:i+3], "%02x", &b); err == nil {
+					out.WriteByte(b)
+					i += 2
+					continue
+				}
+			}
+			out.WriteString(`\x`)
+		default:
+			out.WriteByte(input[i])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated $' quote")
+}
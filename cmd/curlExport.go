@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	app "github.com/derrick-gopher/dash/utils"
+)
+
+// printCurl prints the curl command of the scenarios with a given name. The
+// config is read without its init functions, so their aliases and the auth
+// credentials are left as placeholders.
+func printCurl(args []string) error {
+	flags := flag.NewFlagSet("curl", flag.ContinueOnError)
+	configsPath := flags.String("c", "", "config file")
+	scenarioPath := flags.String("s", "", "scenarios directory/file")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *configsPath == "" || *scenarioPath == "" || flags.NArg() != 1 {
+		return fmt.Errorf("usage: dash curl -c configs.yaml -s scenarios <scenario name>")
+	}
+	if !strings.HasSuffix(*configsPath, "yaml") {
+		return fmt.Errorf("provide a configuration yaml file")
+	}
+	config := loadConfigs(*configsPath)
+	wanted := flags.Arg(0)
+	var matches []app.Scenario
+	for _, scenario := range GetScenarios(scenarioPath) {
+		if scenario.Scenario == wanted {
+			matches = append(matches, scenario)
+		}
+	}
+	if len(matches) == 0 {
+		return fmt.Errorf("no scenario %s in %s", wanted, *scenarioPath)
+	}
+	for i, scenario := range matches {
+		if len(matches) > 1 {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("# %s (%s)\n", scenario.Scenario, strings.TrimSuffix(scenario.Dir, "/"))
+		}
+		fmt.Println(app.CurlCommand(scenario, config))
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	app "github.com/derrick-gopher/dash/utils"
+	"github.com/jinzhu/copier"
+)
+
+// expandExamples turns a scenario with an examples table into one scenario per
+// row. The row's columns become template variables of that instance. A
+// scenario without examples is returned unchanged.
+func expandExamples(scenario app.Scenario, baseDir string) ([]app.Scenario, error) {
+	rows, err := loadExamples(scenario, baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("scenario '%s': %v", scenario.Scenario, err)
+	}
+	if rows == nil {
+		return []app.Scenario{scenario}, nil
+	}
+	var instances []app.Scenario
+	for r, row := range rows {
+		instance := app.Scenario{}
+		if err := copier.Copy(&instance, scenario); err != nil {
+			return nil, err
+		}
+		instance.Row = r + 1
+		instance.Scenario = app.ExampleName(scenario.Scenario, instance.Row)
+		instance.Examples, instance.ExamplesData = nil, nil
+		instance.ExampleRow = row
+		instances = append(instances, instance)
+	}
+	return instances, nil
+}
+
+// loadExamples reads the rows from the examples file or from the inline table
+// held by examples/examplesdata.
+func loadExamples(scenario app.Scenario, baseDir string) ([]map[string]string, error) {
+	source := scenario.Examples
+	if source == nil {
+		source = scenario.ExamplesData
+	}
+	switch examples := source.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if examples == "" {
+			return nil, nil
+		}
+		rows, err := readExamplesFile(examples, baseDir)
+		if err == nil && len(rows) == 0 {
+			err = fmt.Errorf("examples file %s has no rows", examples)
+		}
+		return rows, err
+	case []interface{}:
+		rows, err := inlineExamples(examples)
+		if err == nil && len(rows) == 0 {
+			err = fmt.Errorf("examples table has no rows")
+		}
+		return rows, err
+	default:
+		return nil, fmt.Errorf("examples must be a file path or a table, got %T", source)
+	}
+}
+
+func readExamplesFile(file string, baseDir string) ([]map[string]string, error) {
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(baseDir, file)
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".csv":
+		rows, err := csvExamples(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		return rows, nil
+	case ".json":
+		var rows []map[string]interface{}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&rows); err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		return stringRows(rows), nil
+	case ".jsonl":
+		var rows []map[string]interface{}
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+		for line := 1; scanner.Scan(); line++ {
+			text := strings.TrimSpace(scanner.Text())
+			if text == "" {
+				continue
+			}
+			var row map[string]interface{}
+			decoder := json.NewDecoder(strings.NewReader(text))
+			decoder.UseNumber()
+			if err := decoder.Decode(&row); err != nil {
+				return nil, fmt.Errorf("%s line %d: %v", file, line, err)
+			}
+			rows = append(rows, row)
+		}
+		return stringRows(rows), scanner.Err()
+	}
+	return nil, fmt.Errorf("unsupported examples file %s, use csv, json or jsonl", file)
+}
+
+func csvExamples(data []byte) ([]map[string]string, error) {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return []map[string]string{}, nil
+	}
+	header := records[0]
+	rows := make([]map[string]string, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := map[string]string{}
+		for c, column := range header {
+			row[strings.TrimSpace(column)] = record[c]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// inlineExamples accepts either a list of maps or a list of lists whose first
+// entry is the header row.
+func inlineExamples(table []interface{}) ([]map[string]string, error) {
+	rows := make([]map[string]string, 0, len(table))
+	var header []string
+	for i, entry := range table {
+		switch values := entry.(type) {
+		case map[string]interface{}:
+			row := map[string]string{}
+			for k, v := range values {
+				row[k] = cellValue(v)
+			}
+			rows = append(rows, row)
+		case []interface{}:
+			if header == nil {
+				for _, v := range values {
+					header = append(header, cellValue(v))
+				}
+				continue
+			}
+			if len(values) != len(header) {
+				return nil, fmt.Errorf("examples row %d has %d cells, the header has %d", i+1, len(values), len(header))
+			}
+			row := map[string]string{}
+			for c, v := range values {
+				row[header[c]] = cellValue(v)
+			}
+			rows = append(rows, row)
+		default:
+			return nil, fmt.Errorf("examples row %d must be a map or a list", i+1)
+		}
+	}
+	return rows, nil
+}
+
+func stringRows(rows []map[string]interface{}) []map[string]string {
+	out := make([]map[string]string, 0, len(rows))
+	for _, values := range rows {
+		row := map[string]string{}
+		for k, v := range values {
+			row[k] = cellValue(v)
+		}
+		out = append(out, row)
+	}
+	return out
+}
+
+// cellValue renders scalars as text and nested values as json so they can be
+// dropped straight into a request body.
+func cellValue(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case map[string]interface{}, []interface{}:
+		out, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Sprint(value)
+		}
+		return string(out)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	app "github.com/derrick-gopher/dash/utils"
+	"gopkg.in/yaml.v3"
+)
+
+func TestExpandExamples(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"users.csv":   "name, job\nmorpheus,leader\n\"Smith, Agent\",\"\"\n",
+		"users.json":  `[{"name": "morpheus", "age": 40, "tags": ["a"]}, {"name": "trinity", "admin": true, "manager": null}]`,
+		"users.jsonl": "{\"name\": \"morpheus\", \"id\": 12345678901234567890}\n\n{\"name\": \"trinity\", \"address\": {\"city\": \"Zion\"}}\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		scenario string
+		want     []map[string]string
+	}{
+		{
+			name:     "csv trims the header and keeps quoted commas",
+			scenario: "examples: users.csv",
+			want:     []map[string]string{{"name": "morpheus", "job": "leader"}, {"name": "Smith, Agent", "job": ""}},
+		},
+		{
+			name:     "json renders numbers, booleans, null and nested values",
+			scenario: "examples: users.json",
+			want:     []map[string]string{{"name": "morpheus", "age": "40", "tags": `["a"]`}, {"name": "trinity", "admin": "true", "manager": ""}},
+		},
+		{
+			name:     "jsonl skips blank lines and keeps big numbers intact",
+			scenario: "examples: users.jsonl",
+			want:     []map[string]string{{"name": "morpheus", "id": "12345678901234567890"}, {"name": "trinity", "address": `{"city":"Zion"}`}},
+		},
+		{
+			name:     "absolute path",
+			scenario: "examples: " + filepath.Join(dir, "users.csv"),
+			want:     []map[string]string{{"name": "morpheus", "job": "leader"}, {"name": "Smith, Agent", "job": ""}},
+		},
+		{
+			name:     "inline list of maps",
+			scenario: "examples:\n  - {name: neo, job: the one}\n  - {name: oracle, age: 70}",
+			want:     []map[string]string{{"name": "neo", "job": "the one"}, {"name": "oracle", "age": "70"}},
+		},
+		{
+			name:     "inline list of lists with a header row",
+			scenario: "examples:\n  - [name, job]\n  - [neo, the one]\n  - [tank, operator]",
+			want:     []map[string]string{{"name": "neo", "job": "the one"}, {"name": "tank", "job": "operator"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var scenario app.Scenario
+			if err := yaml.Unmarshal([]byte("scenario: Create user\n"+test.scenario), &scenario); err != nil {
+				t.Fatal(err)
+			}
+			instances, err := expandExamples(scenario, dir)
+			if err != nil {
+				t.Fatalf("expandExamples() failed: %v", err)
+			}
+			if len(instances) != len(test.want) {
+				t.Fatalf("expandExamples() gave %d instances, want %d", len(instances), len(test.want))
+			}
+			for i, instance := range instances {
+				if instance.Row != i+1 || instance.Scenario != app.ExampleName("Create user", i+1) {
+					t.Errorf("instance %d is %q row %d", i, instance.Scenario, instance.Row)
+				}
+				if !reflect.DeepEqual(instance.ExampleRow, test.want[i]) {
+					t.Errorf("row %d = %v, want %v", i+1, instance.ExampleRow, test.want[i])
+				}
+				if instance.Examples != nil || instance.ExamplesData != nil {
+					t.Errorf("row %d still holds the examples table", i+1)
+				}
+			}
+		})
+	}
+}
+
+func TestExpandExamplesWithoutExamples(t *testing.T) {
+	scenario := app.Scenario{Scenario: "Plain", Url: "/users"}
+	instances, err := expandExamples(scenario, ".")
+	if err != nil || len(instances) != 1 || instances[0].Scenario != "Plain" || instances[0].Row != 0 {
+		t.Errorf("expandExamples() = %v, %v, want the scenario unchanged", instances, err)
+	}
+}
+
+func TestExpandExamplesErrors(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"users.xml":    "<users/>",
+		"broken.json":  `[{"name": "neo"`,
+		"broken.jsonl": "{\"name\": \"neo\"}\n{name: trinity}\n",
+		"empty.csv":    "",
+		"header.csv":   "name,job\n",
+		"empty.json":   "[]",
+		"empty.jsonl":  "\n\n",
+		"ragged.csv":   "name,job\nneo,the one\ntank\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		scenario string
+		err      string
+	}{
+		{scenario: "examples: missing.csv", err: "missing.csv"},
+		{scenario: "examples: users.xml", err: "unsupported examples file"},
+		{scenario: "examples: broken.json", err: "broken.json"},
+		{scenario: "examples: broken.jsonl", err: "broken.jsonl line 2"},
+		{scenario: "examples: 42", err: "examples must be a file path or a table"},
+		{scenario: "examples:\n  - neo", err: "examples row 1 must be a map or a list"},
+		{scenario: "examples: empty.csv", err: "examples file empty.csv has no rows"},
+		{scenario: "examples: header.csv", err: "examples file header.csv has no rows"},
+		{scenario: "examples: empty.json", err: "examples file empty.json has no rows"},
+		{scenario: "examples: empty.jsonl", err: "examples file empty.jsonl has no rows"},
+		{scenario: "examples: []", err: "examples table has no rows"},
+		{scenario: "examples:\n  - [name, job]", err: "examples table has no rows"},
+		{scenario: "examples: ragged.csv", err: "ragged.csv: record on line 3: wrong number of fields"},
+		{scenario: "examples:\n  - [name, job]\n  - [neo]", err: "examples row 2 has 1 cells, the header has 2"},
+		{scenario: "examples:\n  - [name]\n  - [neo, the one]", err: "examples row 2 has 2 cells, the header has 1"},
+	}
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+			var scenario app.Scenario
+			if err := yaml.Unmarshal([]byte("scenario: Create user\n"+test.scenario), &scenario); err != nil {
+				t.Fatal(err)
+			}
+			_, err := expandExamples(scenario, dir)
+			if err == nil || !strings.HasPrefix(err.Error(), "scenario 'Create user': ") || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("expandExamples() error = %v, want %q", err, test.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The importers and generators write scenarios and configs through these
+// types rather than app.Scenario and app.Config, so that the files only hold
+// the fields that were set. Keys match what GetScenarios and GetConfigs read.
+
+type scenarioSpec struct {
+	Scenario        string            `yaml:"scenario"`
+	Service         string            `yaml:"service,omitempty"`
+	Labels          []string          `yaml:"labels,omitempty"`
+	Method          string            `yaml:"method,omitempty"`
+	Url             string            `yaml:"url"`
+	Tag             string            `yaml:"tag,omitempty"`
+	Params          map[string]string `yaml:"params,omitempty"`
+	Headers         map[string]string `yaml:"headers,omitempty"`
+	Auth            *authSpec         `yaml:"auth,omitempty"`
+	Body            string            `yaml:"body,omitempty"`
+	Status          int               `yaml:"status"`
+	MaxResponseTime string            `yaml:"max_response_time,omitempty"`
+	Validators      []validatorSpec   `yaml:"validators,omitempty"`
+	Capture         map[string]string `yaml:"capture,omitempty"`
+}
+
+type validatorSpec struct {
+	Validate validateSpec `yaml:"validate"`
+}
+
+type validateSpec struct {
+	Type       string `yaml:"type,omitempty"`
+	Extract    string `yaml:"extract,omitempty"`
+	Comparator string `yaml:"comparator,omitempty"`
+	Expected   string `yaml:"expected,omitempty"`
+	Schema     string `yaml:"schema,omitempty"`
+}
+
+type authSpec struct {
+	Type     string      `yaml:"type"`
+	Values   string      `yaml:"values,omitempty"`
+	Username string      `yaml:"username,omitempty"`
+	Password string      `yaml:"password,omitempty"`
+	Token    string      `yaml:"token,omitempty"`
+	Key      string      `yaml:"key,omitempty"`
+	In       string      `yaml:"in,omitempty"`
+	OAuth2   *oauth2Spec `yaml:"oauth2,omitempty"`
+}
+
+type oauth2Spec struct {
+	GrantType    string `yaml:"granttype,omitempty"`
+	TokenURL     string `yaml:"tokenurl,omitempty"`
+	ClientID     string `yaml:"clientid,omitempty"`
+	ClientSecret string `yaml:"clientsecret,omitempty"`
+	ClientAuth   string `yaml:"clientauth,omitempty"`
+	Username     string `yaml:"username,omitempty"`
+	Password     string `yaml:"password,omitempty"`
+	Scope        string `yaml:"scope,omitempty"`
+}
+
+type serviceSpec struct {
+	Name    string            `yaml:"name"`
+	Tag     string            `yaml:"tag,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+	Auth    *authSpec         `yaml:"auth,omitempty"`
+}
+
+type configSpec struct {
+	Services []serviceSpec     `yaml:"services,omitempty"`
+	Data     map[string]string `yaml:"data,omitempty"`
+	Headers  map[string]string `yaml:"headers,omitempty"`
+	Auth     *authSpec         `yaml:"auth,omitempty"`
+	Metadata metadataSpec      `yaml:"metadata,omitempty"`
+}
+
+type metadataSpec struct {
+	Project     string `yaml:"project,omitempty"`
+	Environment string `yaml:"environment,omitempty"`
+	Collection  string `yaml:"collection,omitempty"`
+}
+
+var unsafeName = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// fileName turns a collection, folder or tag name into a file or directory
+// name. getWalking skips directories called skip, so those get a suffix.
+func fileName(name string) string {
+	out := strings.Trim(unsafeName.ReplaceAllString(strings.ToLower(name), "_"), "_.")
+	if out == "" {
+		out = "unnamed"
+	}
+	if out == "skip" {
+		out = "skip_"
+	}
+	return out
+}
+
+// writeYAML writes value as yaml to path, creating its directory.
+func writeYAML(path string, value interface{}) error {
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(value); err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+	return writeFile(path, out.Bytes())
+}
+
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("writing %s: %v", path, err)
+	}
+	return nil
+}
+
+// migrationReport lists what an importer could not translate, or what a
+// generator left to fill in. intro replaces the default heading of the list.
+type migrationReport struct {
+	title   string
+	intro   string
+	entries []string
+}
+
+func (report *migrationReport) add(where string, format string, args ...interface{}) {
+	report.entries = append(report.entries, fmt.Sprintf("- **%s**: %s", where, fmt.Sprintf(format, args...)))
+}
+
+func (report *migrationReport) write(path string) error {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "# %s\n\n", report.title)
+	if len(report.entries) == 0 {
+		out.WriteString("Everything was translated.\n")
+	} else {
+		intro := report.intro
+		if intro == "" {
+			intro = "These parts could not be translated and need a manual look:"
+		}
+		out.WriteString(intro + "\n\n")
+		out.WriteString(strings.Join(report.entries, "\n") + "\n")
+	}
+	return writeFile(path, out.Bytes())
+}
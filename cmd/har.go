@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+type harFile struct {
+	Log struct {
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type harEntry struct {
+	ResourceType string `json:"_resourceType"`
+	Request      struct {
+		Method   string      `json:"method"`
+		URL      string      `json:"url"`
+		Headers  []harRecord `json:"headers"`
+		PostData *struct {
+			MimeType string      `json:"mimeType"`
+			Text     string      `json:"text"`
+			Encoding string      `json:"encoding"`
+			Params   []harRecord `json:"params"`
+		} `json:"postData"`
+	} `json:"request"`
+	Response struct {
+		Status int `json:"status"`
+	} `json:"response"`
+}
+
+type harRecord struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// harResourceTypes are the entries kept by default: the api calls and page
+// loads, not the images, scripts and stylesheets the browser fetched.
+var harResourceTypes = map[string]bool{"": true, "xhr": true, "fetch": true, "document": true}
+
+func importHAR(args []string) error {
+	flags := flag.NewFlagSet("import har", flag.ContinueOnError)
+	out := flags.String("out", "", "directory to write configs.yaml and the scenarios to, defaults to the file name")
+	all := flags.Bool("all", false, "import every entry, including images, scripts and stylesheets")
+	include := flags.String("include", "", "only import the requests whose url matches this regex")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: dash import har [-out dir] [-all] [-include regex] <file.har>")
+	}
+	var match *regexp.Regexp
+	if *include != "" {
+		var err error
+		if match, err = regexp.Compile(*include); err != nil {
+			return fmt.Errorf("invalid -include: %v", err)
+		}
+	}
+	data, err := ioutil.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	var har harFile
+	if err := json.Unmarshal(data, &har); err != nil {
+		return fmt.Errorf("reading har file: %v", err)
+	}
+	name := strings.TrimSuffix(filepath.Base(flags.Arg(0)), filepath.Ext(flags.Arg(0)))
+	dir := *out
+	if dir == "" {
+		dir = fileName(name)
+	}
+
+	importer := newCaptureImporter("Import report for " + name)
+	var resources, excluded, inline int
+	for _, entry := range har.Log.Entries {
+		switch {
+		case strings.HasPrefix(entry.Request.URL, "data:") || strings.HasPrefix(entry.Request.URL, "blob:"):
+			inline++
+			continue
+		case !*all && !harResourceTypes[entry.ResourceType]:
+			resources++
+			continue
+		case match != nil && !match.MatchString(entry.Request.URL):
+			excluded++
+			continue
+		}
+		request := capturedRequest{Method: strings.ToUpper(entry.Request.Method), URL: entry.Request.URL, Status: entry.Response.Status}
+		for _, header := range entry.Request.Headers {
+			request.Headers = append(request.Headers, [2]string{header.Name, header.Value})
+		}
+		if postData := entry.Request.PostData; postData != nil {
+			request.Body = postData.Text
+			if postData.Encoding == "base64" {
+				decoded, err := base64.StdEncoding.DecodeString(postData.Text)
+				if err != nil {
+					importer.report.add(request.Method+" "+request.URL, "the base64 body cannot be decoded, the request is skipped")
+					continue
+				}
+				request.Body = string(decoded)
+			}
+			if request.Body == "" && len(postData.Params) > 0 {
+				var form []string
+				for _, param := range postData.Params {
+					form = append(form, formEscape(param.Name)+"="+formEscape(param.Value))
+				}
+				request.Body = strings.Join(form, "&")
+			}
+			if !hasHeader(request.Headers, "Content-Type") && postData.MimeType != "" {
+				request.Headers = append(request.Headers, [2]string{"Content-Type", postData.MimeType})
+			}
+		}
+		importer.add(request)
+	}
+	if resources > 0 {
+		importer.report.add(name, "%d entries for images, scripts, stylesheets and other resources were left out, use -all to import them", resources)
+	}
+	if excluded > 0 {
+		importer.report.add(name, "%d entries do not match -include and were left out", excluded)
+	}
+	if inline > 0 {
+		importer.report.add(name, "%d entries with data: or blob: urls were left out, they are not sent over the network", inline)
+	}
+	return importer.write(dir, configSpec{Metadata: metadataSpec{Collection: name}}, "import-report.md")
+}
+
+func hasHeader(headers [][2]string, name string) bool {
+	for _, header := range headers {
+		if strings.EqualFold(header[0], name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,415 @@
+package cmd
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	app "github.com/derrick-gopher/dash/utils"
+)
+
+// openAPIGenerator writes one scenario per operation, grouped in one file per
+// service. Services are the tags of the spec.
+type openAPIGenerator struct {
+	spec      *app.OpenAPISpec
+	dir       string
+	report    *migrationReport
+	names     map[string]int
+	schemas   map[string]bool
+	scenarios map[string][]scenarioSpec
+}
+
+func generateOpenAPI(args []string) error {
+	flags := flag.NewFlagSet("generate openapi", flag.ContinueOnError)
+	out := flags.String("out", "", "directory to write configs.yaml and the scenarios to, defaults to the spec title")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: dash generate openapi [-out dir] <spec.yaml>")
+	}
+	spec, err := app.LoadOpenAPI(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	title := spec.Title
+	if title == "" {
+		title = strings.TrimSuffix(filepath.Base(flags.Arg(0)), filepath.Ext(flags.Arg(0)))
+	}
+	dir := *out
+	if dir == "" {
+		dir = fileName(title)
+	}
+	generator := &openAPIGenerator{
+		spec:      spec,
+		dir:       filepath.Join(dir, "scenarios"),
+		report:    &migrationReport{title: "Generation report for " + title, intro: "These parts need a manual look before the scenarios run:"},
+		names:     map[string]int{},
+		schemas:   map[string]bool{},
+		scenarios: map[string][]scenarioSpec{},
+	}
+
+	config := configSpec{Data: map[string]string{}, Metadata: metadataSpec{Project: title}}
+	if len(spec.Servers) > 0 {
+		config.Data["base_url"] = spec.Servers[0]
+		if !strings.Contains(spec.Servers[0], "://") {
+			generator.report.add("servers", "server url `%s` is relative, set the host in data.base_url", spec.Servers[0])
+		}
+	} else {
+		config.Data["base_url"] = "http://localhost"
+		generator.report.add("servers", "the spec has no servers, set data.base_url")
+	}
+	config.Auth = generator.auth(config.Data)
+	for _, op := range spec.Operations {
+		scenario, err := generator.scenario(op)
+		if err != nil {
+			return err
+		}
+		generator.scenarios[scenario.Service] = append(generator.scenarios[scenario.Service], scenario)
+	}
+
+	services := make([]string, 0, len(generator.scenarios))
+	for service := range generator.scenarios {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	for _, service := range services {
+		file := "untagged"
+		if service != "" {
+			config.Services = append(config.Services, serviceSpec{Name: service})
+			file = fileName(service)
+		}
+		if err := writeYAML(filepath.Join(generator.dir, file+".yaml"), generator.scenarios[service]); err != nil {
+			return err
+		}
+	}
+	if err := writeYAML(filepath.Join(dir, "configs.yaml"), config); err != nil {
+		return err
+	}
+	if err := generator.report.write(filepath.Join(dir, "generate-report.md")); err != nil {
+		return err
+	}
+	fmt.Printf("Generated %d scenarios for %d services under %s, %d notes (see %s).\n",
+		len(spec.Operations), len(config.Services), dir, len(generator.report.entries), filepath.Join(dir, "generate-report.md"))
+	fmt.Printf("Run them with: dash -c %s -s %s\n", filepath.Join(dir, "configs.yaml"), generator.dir)
+	return nil
+}
+
+func (generator *openAPIGenerator) scenario(op *app.OpenAPIOperation) (scenarioSpec, error) {
+	where := op.Method + " " + op.Path
+	spec := scenarioSpec{
+		Scenario: generator.name(op),
+		Method:   op.Method,
+		Labels:   op.Tags,
+		Status:   op.SuccessStatus(),
+	}
+	if len(op.Tags) > 0 {
+		spec.Service = op.Tags[0]
+	}
+
+	path := op.Path
+	for _, parameter := range op.Parameters {
+		value, ok := parameterValue(parameter)
+		switch parameter.In {
+		case "path":
+			path = strings.ReplaceAll(path, "{"+parameter.Name+"}", url.PathEscape(value))
+		case "query":
+			if parameter.Required || ok {
+				if spec.Params == nil {
+					spec.Params = map[string]string{}
+				}
+				spec.Params[parameter.Name] = value
+			}
+		case "header":
+			switch strings.ToLower(parameter.Name) {
+			case "accept", "content-type", "authorization":
+				continue
+			}
+			if parameter.Required || ok {
+				if spec.Headers == nil {
+					spec.Headers = map[string]string{}
+				}
+				spec.Headers[parameter.Name] = value
+			}
+		default:
+			if parameter.Required {
+				generator.report.add(where, "%s parameter `%s` is not generated", parameter.In, parameter.Name)
+			}
+		}
+	}
+	spec.Url = "{{base_url}}" + path
+
+	if op.Body != nil && op.Body.MediaType != "" {
+		generator.body(&spec, op.Body, where)
+	}
+
+	contents, _ := op.Response(spec.Status)
+	for _, content := range contents {
+		if content.Schema == nil || !strings.Contains(strings.ToLower(content.MediaType), "json") {
+			continue
+		}
+		file := filepath.Join("schemas", fmt.Sprintf("%s-%d.json", fileName(spec.Scenario), spec.Status))
+		if err := generator.writeSchema(file, content.Schema); err != nil {
+			return spec, err
+		}
+		spec.Validators = append(spec.Validators,
+			validatorSpec{Validate: validateSpec{Type: "header", Extract: "Content-Type", Comparator: "contains", Expected: content.MediaType}},
+			validatorSpec{Validate: validateSpec{Type: "schema", Schema: filepath.ToSlash(file)}})
+		break
+	}
+	return spec, nil
+}
+
+// name is the summary, operationId or method and path of an operation,
+// made unique within the run.
+func (generator *openAPIGenerator) name(op *app.OpenAPIOperation) string {
+	name := op.Summary
+	if name == "" {
+		name = op.OperationID
+	}
+	if name == "" {
+		name = op.Method + " " + op.Path
+	}
+	generator.names[name]++
+	if generator.names[name] > 1 {
+		name = fmt.Sprintf("%s (%s %s)", name, op.Method, op.Path)
+	}
+	return name
+}
+
+func (generator *openAPIGenerator) body(spec *scenarioSpec, body *app.OpenAPIContent, where string) {
+	example := body.Example
+	if example == nil {
+		example = sampleValue(body.Schema, 0)
+	}
+	mediaType := strings.ToLower(body.MediaType)
+	switch {
+	case strings.Contains(mediaType, "json"):
+		out, err := json.MarshalIndent(example, "", "  ")
+		if err != nil {
+			generator.report.add(where, "the request body example cannot be written as json: %v", err)
+			return
+		}
+		spec.Body = string(out)
+	case mediaType == "application/x-www-form-urlencoded":
+		fields, _ := example.(map[string]interface{})
+		form := url.Values{}
+		for name, value := range fields {
+			form.Set(name, textValue(value))
+		}
+		spec.Body = form.Encode()
+		spec.Tag = "urlencoded"
+	default:
+		generator.report.add(where, "%s request bodies are not generated", body.MediaType)
+		return
+	}
+	if spec.Headers == nil {
+		spec.Headers = map[string]string{}
+	}
+	spec.Headers["Content-Type"] = body.MediaType
+}
+
+func (generator *openAPIGenerator) writeSchema(file string, schema map[string]interface{}) error {
+	if generator.schemas[file] {
+		return nil
+	}
+	generator.schemas[file] = true
+	out, err := json.MarshalIndent(app.JSONSchema(schema), "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(generator.dir, file), append(out, '\n'))
+}
+
+// auth maps the first security requirement of the spec onto the config auth.
+// The credentials are left as data entries to fill in.
+func (generator *openAPIGenerator) auth(data map[string]string) *authSpec {
+	if len(generator.spec.Security) == 0 {
+		return nil
+	}
+	name := generator.spec.Security[0]
+	scheme := generator.spec.SecuritySchemes[name]
+	kind, _ := scheme["type"].(string)
+	httpScheme, _ := scheme["scheme"].(string)
+	placeholder := func(keys ...string) {
+		for _, key := range keys {
+			data[key] = ""
+		}
+		generator.report.add("security", "fill in %s in data for the %s security scheme", strings.Join(keys, ", "), name)
+	}
+	switch {
+	case kind == "basic" || (kind == "http" && strings.EqualFold(httpScheme, "basic")):
+		placeholder("username", "password")
+		return &authSpec{Type: "basic", Username: "{{username}}", Password: "{{password}}"}
+	case kind == "http" && strings.EqualFold(httpScheme, "bearer"):
+		placeholder("token")
+		return &authSpec{Type: "bearer", Token: "{{token}}"}
+	case kind == "http" && strings.EqualFold(httpScheme, "digest"):
+		placeholder("username", "password")
+		return &authSpec{Type: "digest", Username: "{{username}}", Password: "{{password}}"}
+	case kind == "apiKey" && scheme["in"] != "cookie":
+		placeholder("api_key")
+		spec := &authSpec{Type: "apikey", Key: fmt.Sprint(scheme["name"]), Values: "{{api_key}}"}
+		if scheme["in"] == "query" {
+			spec.In = "query"
+		}
+		return spec
+	case kind == "oauth2":
+		grant, tokenURL := oauth2Flow(scheme)
+		if grant == "" {
+			break
+		}
+		oauth2 := &oauth2Spec{GrantType: grant, TokenURL: tokenURL, ClientID: "{{client_id}}", ClientSecret: "{{client_secret}}"}
+		if grant == "password" {
+			oauth2.Username, oauth2.Password = "{{username}}", "{{password}}"
+			placeholder("client_id", "client_secret", "username", "password")
+		} else {
+			placeholder("client_id", "client_secret")
+		}
+		return &authSpec{Type: "oauth2", OAuth2: oauth2}
+	}
+	generator.report.add("security", "the %s security scheme is not supported, set auth by hand", name)
+	return nil
+}
+
+// oauth2Flow picks a client credentials or password flow of an OpenAPI 3 or
+// Swagger 2 oauth2 scheme.
+func oauth2Flow(scheme map[string]interface{}) (string, string) {
+	if flows, ok := scheme["flows"].(map[string]interface{}); ok {
+		if details, ok := flows["clientCredentials"].(map[string]interface{}); ok {
+			return "client_credentials", fmt.Sprint(details["tokenUrl"])
+		}
+		if details, ok := flows["password"].(map[string]interface{}); ok {
+			return "password", fmt.Sprint(details["tokenUrl"])
+		}
+		return "", ""
+	}
+	switch scheme["flow"] {
+	case "application":
+		return "client_credentials", fmt.Sprint(scheme["tokenUrl"])
+	case "password":
+		return "password", fmt.Sprint(scheme["tokenUrl"])
+	}
+	return "", ""
+}
+
+// parameterValue is the example of a parameter, or a value made up from its
+// schema. ok tells whether the spec gave an example.
+func parameterValue(parameter app.OpenAPIParameter) (string, bool) {
+	if parameter.Example != nil {
+		return textValue(parameter.Example), true
+	}
+	if parameter.Schema != nil {
+		if _, ok := parameter.Schema["default"]; ok {
+			return textValue(parameter.Schema["default"]), true
+		}
+	}
+	return textValue(sampleValue(parameter.Schema, 0)), false
+}
+
+func textValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = textValue(item)
+		}
+		return strings.Join(parts, ",")
+	case map[string]interface{}:
+		out, _ := json.Marshal(v)
+		return string(out)
+	}
+	return fmt.Sprint(value)
+}
+
+// sampleValue makes up a value that matches a schema, preferring its
+// example, default and first enum value.
+func sampleValue(schema map[string]interface{}, depth int) interface{} {
+	if schema == nil || depth > 6 {
+		return nil
+	}
+	for _, key := range []string{"example", "default"} {
+		if value, ok := schema[key]; ok {
+			return value
+		}
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok && len(enum) > 0 {
+		return enum[0]
+	}
+	if all, ok := schema["allOf"].([]interface{}); ok {
+		merged := map[string]interface{}{}
+		for _, part := range all {
+			if fields, ok := sampleValue(asMap(part), depth+1).(map[string]interface{}); ok {
+				for k, v := range fields {
+					merged[k] = v
+				}
+			}
+		}
+		return merged
+	}
+	for _, key := range []string{"oneOf", "anyOf"} {
+		if options, ok := schema[key].([]interface{}); ok && len(options) > 0 {
+			return sampleValue(asMap(options[0]), depth+1)
+		}
+	}
+	kind, _ := schema["type"].(string)
+	if kinds, ok := schema["type"].([]interface{}); ok {
+		for _, k := range kinds {
+			if k != "null" {
+				kind = fmt.Sprint(k)
+				break
+			}
+		}
+	}
+	if kind == "" && schema["properties"] != nil {
+		kind = "object"
+	}
+	switch kind {
+	case "object":
+		out := map[string]interface{}{}
+		for name, property := range asMap(schema["properties"]) {
+			property := asMap(property)
+			if property["readOnly"] == true {
+				continue
+			}
+			out[name] = sampleValue(property, depth+1)
+		}
+		return out
+	case "array":
+		return []interface{}{sampleValue(asMap(schema["items"]), depth+1)}
+	case "integer", "number":
+		if minimum, ok := schema["minimum"]; ok {
+			return minimum
+		}
+		return 1
+	case "boolean":
+		return true
+	case "string":
+		formats := map[string]string{
+			"date":      "2024-01-01",
+			"date-time": "2024-01-01T00:00:00Z",
+			"email":     "user@example.com",
+			"uuid":      "3fa85f64-5717-4562-b3fc-2c963f66afa6",
+			"uri":       "https://example.com",
+			"hostname":  "example.com",
+			"ipv4":      "127.0.0.1",
+			"password":  "secret",
+		}
+		if value, ok := formats[fmt.Sprint(schema["format"])]; ok {
+			return value
+		}
+		return "string"
+	}
+	return nil
+}
+
+func asMap(node interface{}) map[string]interface{} {
+	value, _ := node.(map[string]interface{})
+	return value
+}
@@ -0,0 +1,507 @@
+package cmd
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// postmanCollection is the part of a Postman v2.0 or v2.1 collection the
+// importer reads.
+type postmanCollection struct {
+	Info struct {
+		Name string `json:"name"`
+	} `json:"info"`
+	Item     []postmanItem     `json:"item"`
+	Variable []postmanKeyValue `json:"variable"`
+	Auth     *postmanAuth      `json:"auth"`
+	Event    []postmanEvent    `json:"event"`
+}
+
+// postmanItem is a folder when Request is nil.
+type postmanItem struct {
+	Name     string          `json:"name"`
+	Item     []postmanItem   `json:"item"`
+	Request  *postmanRequest `json:"request"`
+	Auth     *postmanAuth    `json:"auth"`
+	Event    []postmanEvent  `json:"event"`
+	Response []struct {
+		Code int `json:"code"`
+	} `json:"response"`
+}
+
+type postmanRequest struct {
+	Method string            `json:"method"`
+	Header []postmanKeyValue `json:"header"`
+	URL    postmanURL        `json:"url"`
+	Body   *postmanBody      `json:"body"`
+	Auth   *postmanAuth      `json:"auth"`
+}
+
+type postmanURL struct {
+	Raw      string            `json:"raw"`
+	Query    []postmanKeyValue `json:"query"`
+	Variable []postmanKeyValue `json:"variable"`
+}
+
+type postmanBody struct {
+	Mode       string            `json:"mode"`
+	Raw        string            `json:"raw"`
+	URLEncoded []postmanKeyValue `json:"urlencoded"`
+	GraphQL    struct {
+		Query     string `json:"query"`
+		Variables string `json:"variables"`
+	} `json:"graphql"`
+}
+
+type postmanKeyValue struct {
+	Key      string      `json:"key"`
+	Value    postmanText `json:"value"`
+	Disabled bool        `json:"disabled"`
+}
+
+// postmanAuth keeps the parameters of every auth type; only the one named by
+// Type is used.
+type postmanAuth struct {
+	Type   string
+	params map[string]string
+}
+
+type postmanEvent struct {
+	Listen string `json:"listen"`
+	Script struct {
+		Exec postmanLines `json:"exec"`
+	} `json:"script"`
+}
+
+// postmanText is a value that may be written as a string, number or bool.
+type postmanText string
+
+// postmanLines is a script written as one string or a list of lines.
+type postmanLines []string
+
+func (text *postmanText) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*text = postmanText(s)
+		return nil
+	}
+	if string(data) != "null" {
+		*text = postmanText(data)
+	}
+	return nil
+}
+
+func (lines *postmanLines) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*lines = strings.Split(s, "\n")
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	for _, line := range list {
+		*lines = append(*lines, strings.Split(line, "\n")...)
+	}
+	return nil
+}
+
+// UnmarshalJSON reads a request given as just its url.
+func (request *postmanRequest) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err == nil {
+		*request = postmanRequest{Method: "GET", URL: postmanURL{Raw: raw}}
+		return nil
+	}
+	type plain postmanRequest
+	return json.Unmarshal(data, (*plain)(request))
+}
+
+// UnmarshalJSON reads a url given as a string or as an object.
+func (u *postmanURL) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err == nil {
+		*u = postmanURL{Raw: raw}
+		return nil
+	}
+	type plain postmanURL
+	return json.Unmarshal(data, (*plain)(u))
+}
+
+// UnmarshalJSON reads the parameters of v2.1 ([{key, value}]) and v2.0
+// ({key: value}) collections.
+func (auth *postmanAuth) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(fields["type"], &auth.Type); err != nil {
+		return fmt.Errorf("auth without a type")
+	}
+	auth.params = map[string]string{}
+	raw, ok := fields[auth.Type]
+	if !ok {
+		return nil
+	}
+	var list []postmanKeyValue
+	if err := json.Unmarshal(raw, &list); err == nil {
+		for _, param := range list {
+			auth.params[param.Key] = string(param.Value)
+		}
+		return nil
+	}
+	var object map[string]postmanText
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return err
+	}
+	for key, value := range object {
+		auth.params[key] = string(value)
+	}
+	return nil
+}
+
+var postmanDynamic = regexp.MustCompile(`{{\$(\w+)}}`)
+
+// postmanImporter writes one scenario file per folder, mirroring the folder
+// tree of the collection under the scenarios directory.
+type postmanImporter struct {
+	collectionAuth *postmanAuth
+	report         *migrationReport
+	files          int
+	requests       int
+}
+
+func importPostman(args []string) error {
+	flags := flag.NewFlagSet("import postman", flag.ContinueOnError)
+	out := flags.String("out", "", "directory to write configs.yaml and the scenarios to, defaults to the collection name")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: dash import postman [-out dir] <collection.json>")
+	}
+	data, err := ioutil.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	var collection postmanCollection
+	if err := json.Unmarshal(data, &collection); err != nil {
+		return fmt.Errorf("reading postman collection: %v", err)
+	}
+	name := collection.Info.Name
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(flags.Arg(0)), filepath.Ext(flags.Arg(0)))
+	}
+	dir := *out
+	if dir == "" {
+		dir = fileName(name)
+	}
+
+	importer := &postmanImporter{
+		collectionAuth: collection.Auth,
+		report:         &migrationReport{title: "Migration report for " + name},
+	}
+	config := configSpec{Data: map[string]string{}, Metadata: metadataSpec{Collection: name}}
+	for _, variable := range collection.Variable {
+		if !variable.Disabled {
+			config.Data[variable.Key] = importer.text(string(variable.Value), name)
+		}
+	}
+	config.Auth = importer.auth(collection.Auth, name)
+	importer.events(collection.Event, name, "collection")
+	if err := importer.folder(collection.Item, filepath.Join(dir, "scenarios"), fileName(name), collection.Auth, name); err != nil {
+		return err
+	}
+	if err := writeYAML(filepath.Join(dir, "configs.yaml"), config); err != nil {
+		return err
+	}
+	if err := importer.report.write(filepath.Join(dir, "migration-report.md")); err != nil {
+		return err
+	}
+	fmt.Printf("Imported %d requests into %d scenario files under %s, %d items need a manual look (see %s).\n",
+		importer.requests, importer.files, dir, len(importer.report.entries), filepath.Join(dir, "migration-report.md"))
+	fmt.Printf("Run them with: dash -c %s -s %s\n", filepath.Join(dir, "configs.yaml"), filepath.Join(dir, "scenarios"))
+	return nil
+}
+
+func (importer *postmanImporter) folder(items []postmanItem, dir string, file string, auth *postmanAuth, where string) error {
+	var scenarios []scenarioSpec
+	// sibling folders whose names differ only in case or punctuation would
+	// share a directory and overwrite each other's file
+	used := map[string]bool{}
+	for _, item := range items {
+		itemWhere := where + " / " + item.Name
+		if item.Request != nil {
+			scenarios = append(scenarios, importer.scenario(item, auth, itemWhere))
+			continue
+		}
+		folderAuth := auth
+		if item.Auth != nil && item.Auth.Type != "inherit" {
+			folderAuth = item.Auth
+		}
+		importer.events(item.Event, itemWhere, "folder")
+		name := fileName(item.Name)
+		for n := 2; used[name]; n++ {
+			name = fmt.Sprintf("%s_%d", fileName(item.Name), n)
+		}
+		if name != fileName(item.Name) {
+			importer.report.add(itemWhere, "another folder is also named `%s` once made a file name, this one is written to `%s`", fileName(item.Name), name)
+		}
+		used[name] = true
+		if err := importer.folder(item.Item, filepath.Join(dir, name), name, folderAuth, itemWhere); err != nil {
+			return err
+		}
+	}
+	if len(scenarios) == 0 {
+		return nil
+	}
+	importer.files++
+	return writeYAML(filepath.Join(dir, file+".yaml"), scenarios)
+}
+
+func (importer *postmanImporter) scenario(item postmanItem, auth *postmanAuth, where string) scenarioSpec {
+	importer.requests++
+	request := item.Request
+	spec := scenarioSpec{Scenario: item.Name, Method: strings.ToUpper(request.Method), Status: 200}
+	if spec.Method == "" {
+		spec.Method = "GET"
+	}
+	spec.Url, spec.Params = importer.url(request.URL, where)
+	for _, header := range request.Header {
+		if header.Disabled {
+			continue
+		}
+		if spec.Headers == nil {
+			spec.Headers = map[string]string{}
+		}
+		spec.Headers[header.Key] = importer.text(string(header.Value), where)
+	}
+	importer.body(&spec, request.Body, where)
+
+	if request.Auth != nil && request.Auth.Type != "inherit" {
+		auth = request.Auth
+	}
+	if auth != importer.collectionAuth {
+		spec.Auth = importer.auth(auth, where)
+		if spec.Auth == nil && importer.collectionAuth != nil {
+			spec.Auth = &authSpec{Type: "none"}
+		}
+	}
+
+	if len(item.Response) > 0 && item.Response[0].Code != 0 {
+		spec.Status = item.Response[0].Code
+	}
+	for _, event := range item.Event {
+		if event.Listen != "test" {
+			continue
+		}
+		tests := translatePostmanTests(event.Script.Exec)
+		if tests.status != 0 {
+			spec.Status = tests.status
+		}
+		spec.Validators = append(spec.Validators, tests.validators...)
+		if len(tests.capture) > 0 {
+			spec.Capture = tests.capture
+		}
+		if tests.maxResponseTime != "" {
+			spec.MaxResponseTime = tests.maxResponseTime
+		}
+		for _, line := range tests.untranslated {
+			importer.report.add(where, "test `%s`", line)
+		}
+	}
+	importer.events(item.Event, where, "request")
+	return spec
+}
+
+// url splits the url from its query, which becomes the params. Path
+// variables (:id) are filled in from their value or become {{id}}.
+func (importer *postmanImporter) url(u postmanURL, where string) (string, map[string]string) {
+	raw := importer.text(u.Raw, where)
+	query := ""
+	if i := strings.Index(raw, "?"); i >= 0 {
+		raw, query = raw[:i], raw[i+1:]
+	}
+	// only whole path segments are variables, not a longer :name or a port
+	segments := strings.Split(raw, "/")
+	for _, variable := range u.Variable {
+		value := string(variable.Value)
+		if value == "" {
+			value = "{{" + variable.Key + "}}"
+		}
+		for i, segment := range segments {
+			if i > 0 && segment == ":"+variable.Key {
+				segments[i] = importer.text(value, where)
+			}
+		}
+	}
+	raw = strings.Join(segments, "/")
+	params := map[string]string{}
+	add := func(key, value string) {
+		if _, ok := params[key]; ok {
+			importer.report.add(where, "query parameter `%s` is repeated, only the last value is kept", key)
+		}
+		params[key] = importer.text(value, where)
+	}
+	if u.Query != nil {
+		for _, param := range u.Query {
+			if !param.Disabled {
+				add(param.Key, string(param.Value))
+			}
+		}
+	} else if query != "" {
+		for _, pair := range strings.Split(query, "&") {
+			parts := strings.SplitN(pair, "=", 2)
+			key, _ := url.QueryUnescape(parts[0])
+			value := ""
+			if len(parts) == 2 {
+				value, _ = url.QueryUnescape(parts[1])
+			}
+			add(key, value)
+		}
+	}
+	if len(params) == 0 {
+		params = nil
+	}
+	return raw, params
+}
+
+func (importer *postmanImporter) body(spec *scenarioSpec, body *postmanBody, where string) {
+	if body == nil {
+		return
+	}
+	switch body.Mode {
+	case "", "none":
+	case "raw":
+		spec.Body = importer.text(body.Raw, where)
+	case "urlencoded":
+		var pairs []string
+		for _, param := range body.URLEncoded {
+			if !param.Disabled {
+				pairs = append(pairs, formEscape(param.Key)+"="+formEscape(importer.text(string(param.Value), where)))
+			}
+		}
+		spec.Body = strings.Join(pairs, "&")
+		spec.Tag = "urlencoded"
+		if spec.Headers == nil {
+			spec.Headers = map[string]string{}
+		}
+		if _, ok := spec.Headers["Content-Type"]; !ok {
+			spec.Headers["Content-Type"] = "application/x-www-form-urlencoded"
+		}
+	case "graphql":
+		payload := map[string]interface{}{"query": body.GraphQL.Query}
+		if strings.TrimSpace(body.GraphQL.Variables) != "" {
+			payload["variables"] = json.RawMessage(body.GraphQL.Variables)
+		}
+		out, err := json.Marshal(payload)
+		if err != nil {
+			importer.report.add(where, "graphql variables are not valid json")
+			return
+		}
+		spec.Body = importer.text(string(out), where)
+	default:
+		importer.report.add(where, "%s body is not supported", body.Mode)
+	}
+}
+
+// formEscape url-encodes a form value but keeps its {{name}} references.
+func formEscape(value string) string {
+	var out strings.Builder
+	last := 0
+	for _, loc := range regex.FindAllStringIndex(value, -1) {
+		out.WriteString(url.QueryEscape(value[last:loc[0]]))
+		out.WriteString(value[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	out.WriteString(url.QueryEscape(value[last:]))
+	return out.String()
+}
+
+func (importer *postmanImporter) auth(auth *postmanAuth, where string) *authSpec {
+	if auth == nil {
+		return nil
+	}
+	params := auth.params
+	for key, value := range params {
+		params[key] = importer.text(value, where)
+	}
+	switch auth.Type {
+	case "noauth", "inherit":
+		return nil
+	case "basic", "digest":
+		return &authSpec{Type: auth.Type, Username: params["username"], Password: params["password"]}
+	case "bearer":
+		return &authSpec{Type: "bearer", Token: params["token"]}
+	case "apikey":
+		spec := &authSpec{Type: "apikey", Key: params["key"], Values: params["value"]}
+		if params["in"] == "query" {
+			spec.In = "query"
+		}
+		return spec
+	case "oauth2":
+		grants := map[string]string{"client_credentials": "client_credentials", "password_credentials": "password"}
+		grant, ok := grants[params["grant_type"]]
+		if !ok {
+			if params["accessToken"] != "" {
+				importer.report.add(where, "oauth2 %s grant is not supported, the saved access token is used as a bearer token", params["grant_type"])
+				return &authSpec{Type: "bearer", Token: params["accessToken"]}
+			}
+			importer.report.add(where, "oauth2 %s grant is not supported", params["grant_type"])
+			return nil
+		}
+		clientAuth := "header"
+		if params["client_authentication"] == "body" {
+			clientAuth = "body"
+		}
+		return &authSpec{Type: "oauth2", OAuth2: &oauth2Spec{
+			GrantType:    grant,
+			TokenURL:     params["accessTokenUrl"],
+			ClientID:     params["clientId"],
+			ClientSecret: params["clientSecret"],
+			ClientAuth:   clientAuth,
+			Username:     params["username"],
+			Password:     params["password"],
+			Scope:        params["scope"],
+		}}
+	}
+	importer.report.add(where, "%s auth is not supported", auth.Type)
+	return nil
+}
+
+// events reports the scripts the importer does not translate: pre-request
+// scripts, and test scripts outside of requests.
+func (importer *postmanImporter) events(events []postmanEvent, where string, level string) {
+	for _, event := range events {
+		if strings.TrimSpace(strings.Join(event.Script.Exec, "")) == "" {
+			continue
+		}
+		if event.Listen == "test" && level == "request" {
+			continue
+		}
+		importer.report.add(where, "%s %s script is not translated", level, event.Listen)
+	}
+}
+
+// text maps the Postman dynamic variables that dash knows and reports the
+// others.
+func (importer *postmanImporter) text(value string, where string) string {
+	return postmanDynamic.ReplaceAllStringFunc(value, func(match string) string {
+		name := postmanDynamic.FindStringSubmatch(match)[1]
+		switch name {
+		case "guid", "randomUUID":
+			return "{{uuid}}"
+		case "timestamp":
+			return "{{timestamp}}"
+		}
+		importer.report.add(where, "dynamic variable `%s` has no dash equivalent", match)
+		return match
+	})
+}
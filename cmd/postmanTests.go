@@ -0,0 +1,441 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// postmanTests translates the pm.* assertions of a Postman test script into
+// a status, validators and captures. Lines it does not understand are kept
+// in untranslated for the migration report.
+type postmanTests struct {
+	aliases         map[string]bool
+	status          int
+	validators      []validatorSpec
+	capture         map[string]string
+	maxResponseTime string
+	untranslated    []string
+}
+
+var (
+	postmanJSONAlias   = regexp.MustCompile(`^\s*(?:var|let|const)\s+(\w+)\s*=\s*(?:pm\.response\.json\(\)|JSON\.parse\(responseBody\))\s*;?\s*$`)
+	postmanBoilerplate = regexp.MustCompile(`^\s*(?://.*)?$|^\s*pm\.test\(.*(?:function\s*\(\s*\)|=>)\s*\{\s*$|^\s*[})\s;]*$`)
+	postmanStatus      = regexp.MustCompile(`pm\.response\.to\.(?:have\.status|be\.status)\(\s*(\d{3})\s*\)|pm\.response\.to\.be\.ok\b`)
+	postmanHeader      = regexp.MustCompile(`pm\.response\.to\.have\.header\(\s*["']([^"']+)["']\s*(?:,\s*["']([^"']*)["']\s*)?\)`)
+	postmanSetter      = regexp.MustCompile(`(?:pm\.(?:environment|collectionVariables|globals|variables)\.set|postman\.set(?:Environment|Global)Variable)\(`)
+	postmanGetter      = regexp.MustCompile(`^(?:pm\.(?:environment|collectionVariables|globals|variables|iterationData)\.get|postman\.get(?:Environment|Global)Variable)\(\s*["']([^"']+)["']\s*\)$`)
+	postmanHeaderGet   = regexp.MustCompile(`^pm\.response\.headers\.get\(\s*["']([^"']+)["']\s*\)$`)
+	postmanPathRoot    = regexp.MustCompile(`^\w+`)
+	postmanPathToken   = regexp.MustCompile(`^(?:\.(\w+)|\[(\d+)\]|\[["']([^"']+)["']\])`)
+	postmanFillers     = map[string]bool{"to": true, "be": true, "been": true, "is": true, "that": true, "which": true, "and": true,
+		"has": true, "have": true, "with": true, "of": true, "same": true, "deep": true, "does": true, "still": true, "at": true}
+)
+
+func translatePostmanTests(lines []string) *postmanTests {
+	tests := &postmanTests{aliases: map[string]bool{}, capture: map[string]string{}}
+	for _, line := range lines {
+		if match := postmanJSONAlias.FindStringSubmatch(line); match != nil {
+			tests.aliases[match[1]] = true
+		}
+	}
+	for _, line := range lines {
+		if postmanJSONAlias.MatchString(line) || postmanBoilerplate.MatchString(line) {
+			continue
+		}
+		if !tests.line(line) {
+			tests.untranslated = append(tests.untranslated, strings.TrimSpace(line))
+		}
+	}
+	return tests
+}
+
+// line translates every assertion of a line, false when the line holds
+// something it cannot translate.
+func (tests *postmanTests) line(line string) bool {
+	found := false
+	for _, match := range postmanStatus.FindAllStringSubmatch(line, -1) {
+		found = true
+		tests.status = 200
+		if match[1] != "" {
+			tests.status, _ = strconv.Atoi(match[1])
+		}
+	}
+	for _, match := range postmanHeader.FindAllStringSubmatch(line, -1) {
+		found = true
+		validate := validateSpec{Type: "header", Extract: match[1], Comparator: "exists"}
+		if match[2] != "" {
+			validate.Comparator, validate.Expected = "eq", match[2]
+		}
+		tests.validators = append(tests.validators, validatorSpec{Validate: validate})
+	}
+	for rest := line; ; {
+		i := strings.Index(rest, "pm.expect(")
+		if i < 0 {
+			break
+		}
+		found = true
+		consumed, ok := tests.expect(rest[i+len("pm.expect"):])
+		if !ok {
+			return false
+		}
+		rest = rest[i+len("pm.expect")+consumed:]
+	}
+	for _, loc := range postmanSetter.FindAllStringIndex(line, -1) {
+		found = true
+		end := closingParen(line, loc[1]-1)
+		if end < 0 {
+			return false
+		}
+		args := splitArgs(line[loc[1]:end])
+		name, ok := jsString(args[0])
+		if len(args) != 2 || !ok {
+			return false
+		}
+		path, ok := tests.jsonPath(args[1])
+		if !ok {
+			return false
+		}
+		tests.capture[name] = path
+	}
+	return found
+}
+
+// expect translates pm.expect(subject).assertion, given the text from the
+// opening parenthesis. It returns how much of the text it read.
+func (tests *postmanTests) expect(text string) (int, bool) {
+	end := closingParen(text, 0)
+	if end < 0 {
+		return 0, false
+	}
+	subject := strings.TrimSpace(text[1:end])
+	words, args, consumed := assertionChain(text[end+1:])
+	consumed += end + 1
+	if len(words) == 0 {
+		return consumed, false
+	}
+	negate := false
+	var assertion string
+	for _, word := range words {
+		switch {
+		case word == "not":
+			negate = !negate
+		case postmanFillers[word]:
+		case assertion != "":
+			// chained assertions such as .an('array').lengthOf(3) are
+			// left to the migration report
+			return consumed, false
+		default:
+			assertion = strings.ToLower(word)
+		}
+	}
+
+	switch {
+	case subject == "pm.response.code":
+		if negate || !(isEquality(assertion) || assertion == "oneof") {
+			return consumed, false
+		}
+		codes := splitArgs(strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(args), "["), "]"))
+		status, err := strconv.Atoi(codes[0])
+		if err != nil {
+			return consumed, false
+		}
+		tests.status = status
+		if len(codes) > 1 {
+			// a scenario checks a single status, so the other codes are
+			// left to the migration report
+			tests.untranslated = append(tests.untranslated,
+				fmt.Sprintf("pm.expect(pm.response.code).to.be.oneOf([%s]) checks status %d only", strings.Join(codes, ", "), status))
+		}
+		return consumed, true
+	case subject == "pm.response.responseTime":
+		if negate || (assertion != "below" && assertion != "lessthan" && assertion != "most") {
+			return consumed, false
+		}
+		if _, err := strconv.Atoi(strings.TrimSpace(args)); err != nil {
+			return consumed, false
+		}
+		tests.maxResponseTime = strings.TrimSpace(args) + "ms"
+		return consumed, true
+	}
+
+	validate := validateSpec{}
+	if header := postmanHeaderGet.FindStringSubmatch(subject); header != nil {
+		validate.Type, validate.Extract = "header", header[1]
+	} else {
+		path, ok := tests.jsonPath(subject)
+		if !ok {
+			return consumed, false
+		}
+		validate.Extract = path
+	}
+	if !setComparator(&validate, assertion, args, negate) {
+		return consumed, false
+	}
+	tests.validators = append(tests.validators, validatorSpec{Validate: validate})
+	return consumed, true
+}
+
+func isEquality(assertion string) bool {
+	return assertion == "eql" || assertion == "equal" || assertion == "equals" || assertion == "eq"
+}
+
+// setComparator maps a chai assertion onto a dash comparator and expected value.
+func setComparator(validate *validateSpec, assertion string, args string, negate bool) bool {
+	pick := func(positive, negative string) bool {
+		if negate {
+			validate.Comparator = negative
+		} else {
+			validate.Comparator = positive
+		}
+		return validate.Comparator != ""
+	}
+	literal := func() bool {
+		value, ok := jsLiteral(args)
+		validate.Expected = value
+		return ok
+	}
+	switch {
+	case isEquality(assertion):
+		return literal() && pick("eq", "ne")
+	case assertion == "include" || assertion == "includes" || assertion == "contain" || assertion == "contains" || assertion == "string":
+		return literal() && pick("contains", "not_contains")
+	case assertion == "above" || assertion == "greaterthan" || assertion == "gt":
+		return literal() && pick("gt", "lte")
+	case assertion == "below" || assertion == "lessthan" || assertion == "lt":
+		return literal() && pick("lt", "gte")
+	case assertion == "least" || assertion == "gte":
+		return literal() && pick("gte", "lt")
+	case assertion == "most" || assertion == "lte":
+		return literal() && pick("lte", "gt")
+	case assertion == "exist" || assertion == "exists":
+		return pick("exists", "not_exists")
+	case assertion == "undefined":
+		return pick("not_exists", "exists")
+	case assertion == "null":
+		return pick("is_null", "not_null")
+	case assertion == "true" || assertion == "false":
+		validate.Expected = assertion
+		return pick("eq", "ne")
+	case assertion == "a" || assertion == "an":
+		return !negate && literal() && pick("type_is", "")
+	case assertion == "lengthof" || assertion == "length":
+		return !negate && literal() && pick("length_eq", "")
+	case assertion == "empty":
+		validate.Expected = "0"
+		return pick("length_eq", "length_gt")
+	case assertion == "match":
+		pattern, ok := jsRegex(args)
+		validate.Expected = pattern
+		return ok && pick("matches", "")
+	case assertion == "oneof":
+		items := splitArgs(strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(args), "["), "]"))
+		for i, item := range items {
+			value, ok := jsLiteral(item)
+			if !ok {
+				return false
+			}
+			items[i] = value
+		}
+		validate.Expected = strings.Join(items, ",")
+		return pick("in", "not_in")
+	case assertion == "property":
+		parts := splitArgs(args)
+		name, ok := jsString(parts[0])
+		if !ok || validate.Type == "header" {
+			return false
+		}
+		validate.Extract = joinPath(validate.Extract, gjsonKey(name))
+		if len(parts) == 2 {
+			args = parts[1]
+			return literal() && pick("eq", "ne")
+		}
+		return pick("exists", "not_exists")
+	}
+	return false
+}
+
+// jsonPath turns jsonData.items[0]["first-name"] into the gjson path
+// items.0.first-name. The root is an alias of pm.response.json().
+func (tests *postmanTests) jsonPath(expr string) (string, bool) {
+	expr = strings.TrimSpace(expr)
+	var rest string
+	switch {
+	case strings.HasPrefix(expr, "pm.response.json()"):
+		rest = strings.TrimPrefix(expr, "pm.response.json()")
+	default:
+		root := postmanPathRoot.FindString(expr)
+		if !tests.aliases[root] {
+			return "", false
+		}
+		rest = expr[len(root):]
+	}
+	path := ""
+	for rest != "" {
+		match := postmanPathToken.FindStringSubmatch(rest)
+		if match == nil {
+			return "", false
+		}
+		path = joinPath(path, gjsonKey(match[1]+match[2]+match[3]))
+		rest = rest[len(match[0]):]
+	}
+	if path == "" {
+		path = "@this"
+	}
+	return path, true
+}
+
+func joinPath(path, key string) string {
+	if path == "" || path == "@this" {
+		return key
+	}
+	return path + "." + key
+}
+
+func gjsonKey(key string) string {
+	return strings.NewReplacer(".", `\.`, "*", `\*`, "?", `\?`).Replace(key)
+}
+
+// assertionChain reads .to.not.eql(1) into its words and the arguments of
+// the final call.
+func assertionChain(text string) ([]string, string, int) {
+	var words []string
+	var args string
+	i := 0
+	for i < len(text) && text[i] == '.' {
+		j := i + 1
+		for j < len(text) && (isWordByte(text[j])) {
+			j++
+		}
+		if j == i+1 {
+			break
+		}
+		words = append(words, text[i+1:j])
+		i = j
+		if i < len(text) && text[i] == '(' {
+			end := closingParen(text, i)
+			if end < 0 {
+				return nil, "", i
+			}
+			args = text[i+1 : end]
+			i = end + 1
+		}
+	}
+	return words, args, i
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9')
+}
+
+// closingParen returns the index of the parenthesis closing the one at open,
+// skipping quoted strings, or -1.
+func closingParen(text string, open int) int {
+	depth := 0
+	var quote byte
+	for i := open; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case c == '(' || c == '[' || c == '{':
+			depth++
+		case c == ')' || c == ']' || c == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitArgs splits call arguments on the commas that are not nested.
+func splitArgs(text string) []string {
+	var args []string
+	start, depth := 0, 0
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case c == '(' || c == '[' || c == '{':
+			depth++
+		case c == ')' || c == ']' || c == '}':
+			depth--
+		case c == ',' && depth == 0:
+			args = append(args, strings.TrimSpace(text[start:i]))
+			start = i + 1
+		}
+	}
+	return append(args, strings.TrimSpace(text[start:]))
+}
+
+func jsString(text string) (string, bool) {
+	text = strings.TrimSpace(text)
+	if len(text) < 2 {
+		return "", false
+	}
+	quote := text[0]
+	if (quote != '"' && quote != '\'' && quote != '`') || text[len(text)-1] != quote {
+		return "", false
+	}
+	inner := text[1 : len(text)-1]
+	if quote == '`' && strings.Contains(inner, "${") {
+		return "", false
+	}
+	return strings.NewReplacer(`\"`, `"`, `\'`, `'`, `\\`, `\`).Replace(inner), true
+}
+
+// jsLiteral reads a string, number, boolean or null literal, or a variable
+// getter that becomes a {{name}} reference.
+func jsLiteral(text string) (string, bool) {
+	text = strings.TrimSpace(text)
+	if value, ok := jsString(text); ok {
+		return value, true
+	}
+	if match := postmanGetter.FindStringSubmatch(text); match != nil {
+		return "{{" + match[1] + "}}", true
+	}
+	if text == "true" || text == "false" || text == "null" {
+		return text, true
+	}
+	if _, err := strconv.ParseFloat(text, 64); err == nil {
+		return text, true
+	}
+	return "", false
+}
+
+// jsRegex turns /pattern/flags into a Go regular expression.
+func jsRegex(text string) (string, bool) {
+	text = strings.TrimSpace(text)
+	end := strings.LastIndex(text, "/")
+	if !strings.HasPrefix(text, "/") || end < 1 {
+		return "", false
+	}
+	pattern, flags := text[1:end], text[end+1:]
+	if strings.Trim(flags, "gim") != "" {
+		return "", false
+	}
+	if strings.Contains(flags, "i") {
+		pattern = "(?i)" + pattern
+	}
+	if _, err := regexp.Compile(pattern); err != nil {
+		return "", false
+	}
+	return strings.ReplaceAll(pattern, `\/`, "/"), true
+}
@@ -0,0 +1,264 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// validators renders translated validators as type|extract|comparator|expected.
+func validators(specs []validatorSpec) []string {
+	var out []string
+	for _, spec := range specs {
+		v := spec.Validate
+		out = append(out, strings.Join([]string{v.Type, v.Extract, v.Comparator, v.Expected}, "|"))
+	}
+	return out
+}
+
+func TestTranslatePostmanTests(t *testing.T) {
+	tests := []struct {
+		name            string
+		script          string
+		status          int
+		validators      []string
+		capture         map[string]string
+		maxResponseTime string
+		untranslated    []string
+	}{
+		{
+			name: "status snippet",
+			script: `pm.test("Status code is 200", function () {
+    pm.response.to.have.status(200);
+});`,
+			status: 200,
+		},
+		{
+			name: "status shorthands",
+			script: `pm.test("Created", () => { pm.response.to.be.status(201); });
+pm.test("Ok", function () { pm.response.to.be.ok; });`,
+			status: 200,
+		},
+		{
+			name: "status code equality",
+			script: `pm.test("Status", function () {
+    pm.expect(pm.response.code).to.eql(204);
+});`,
+			status: 204,
+		},
+		{
+			name: "status code one of",
+			script: `pm.test("Success", function () {
+    pm.expect(pm.response.code).to.be.oneOf([201, 202]);
+});`,
+			status:       201,
+			untranslated: []string{"pm.expect(pm.response.code).to.be.oneOf([201, 202]) checks status 201 only"},
+		},
+		{
+			name: "status code one of a single code",
+			script: `pm.test("Created", function () {
+    pm.expect(pm.response.code).to.be.oneOf([201]);
+});`,
+			status: 201,
+		},
+		{
+			name: "json value snippet",
+			script: `pm.test("Your test name", function () {
+    var jsonData = pm.response.json();
+    pm.expect(jsonData.value).to.eql(100);
+});`,
+			validators: []string{"|value|eq|100"},
+		},
+		{
+			name: "legacy responseBody alias and bracket keys",
+			script: `var data = JSON.parse(responseBody);
+pm.test("first user", function () {
+    pm.expect(data.data[0]["first-name"]).to.equal('George');
+    pm.expect(data["meta.total"]).to.be.above(0);
+});`,
+			validators: []string{"|data.0.first-name|eq|George", `|meta\.total|gt|0`},
+		},
+		{
+			name: "inline pm.response.json()",
+			script: `pm.test("id", () => {
+    pm.expect(pm.response.json().id).to.exist;
+    pm.expect(pm.response.json()).to.have.property('name', "Jane");
+    pm.expect(pm.response.json()).to.have.property("email");
+});`,
+			validators: []string{"|id|exists|", "|name|eq|Jane", "|email|exists|"},
+		},
+		{
+			name: "negations flip the comparator",
+			script: `const json = pm.response.json();
+pm.test("negations", function () {
+    pm.expect(json.count).to.not.be.above(10);
+    pm.expect(json.count).not.to.be.below(1);
+    pm.expect(json.deletedAt).to.be.undefined;
+    pm.expect(json.id).to.not.be.undefined;
+    pm.expect(json.items).to.not.be.empty;
+    pm.expect(json.errors).to.be.empty;
+    pm.expect(json.manager).to.not.be.null;
+    pm.expect(json.name).to.not.eql("root");
+    pm.expect(json.name).to.not.include("admin");
+    pm.expect(json.active).to.not.be.true;
+});`,
+			validators: []string{
+				"|count|lte|10", "|count|gte|1", "|deletedAt|not_exists|", "|id|exists|", "|items|length_gt|0",
+				"|errors|length_eq|0", "|manager|not_null|", "|name|ne|root", "|name|not_contains|admin", "|active|ne|true",
+			},
+		},
+		{
+			name: "types, lengths, regex and one of",
+			script: `var jsonData = pm.response.json();
+pm.test("shape", function () {
+    pm.expect(jsonData.tags).to.be.an('array').that.has.lengthOf(3);
+    pm.expect(jsonData.email).to.match(/@example\.com$/i);
+    pm.expect(jsonData.role).to.be.oneOf(["admin", 'user']);
+    pm.expect(jsonData.id).to.be.a("number");
+});`,
+			validators:   []string{"|email|matches|(?i)@example\\.com$", "|role|in|admin,user", "|id|type_is|number"},
+			untranslated: []string{`pm.expect(jsonData.tags).to.be.an('array').that.has.lengthOf(3);`},
+		},
+		{
+			name: "variables become references",
+			script: `var jsonData = pm.response.json();
+pm.test("same user", function () {
+    pm.expect(jsonData.id).to.eql(pm.environment.get("userId"));
+    pm.expect(jsonData.name).to.eql(pm.collectionVariables.get('name'));
+});`,
+			validators: []string{"|id|eq|{{userId}}", "|name|eq|{{name}}"},
+		},
+		{
+			name: "headers",
+			script: `pm.test("Content-Type is present", function () {
+    pm.response.to.have.header("Content-Type");
+    pm.response.to.have.header("Cache-Control", "no-cache");
+    pm.expect(pm.response.headers.get('X-Request-Id')).to.exist;
+    pm.expect(pm.response.headers.get("Content-Type")).to.include("json");
+});`,
+			validators: []string{"header|Content-Type|exists|", "header|Cache-Control|eq|no-cache", "header|X-Request-Id|exists|", "header|Content-Type|contains|json"},
+		},
+		{
+			name: "response time",
+			script: `pm.test("Response time is less than 200ms", function () {
+    pm.expect(pm.response.responseTime).to.be.below(200);
+});`,
+			maxResponseTime: "200ms",
+		},
+		{
+			name: "captures",
+			script: `var jsonData = pm.response.json();
+pm.environment.set("token", jsonData.access_token);
+postman.setEnvironmentVariable("userId", jsonData.data.id);
+pm.collectionVariables.set('first', jsonData.items[0].id);`,
+			capture: map[string]string{"token": "access_token", "userId": "data.id", "first": "items.0.id"},
+		},
+		{
+			name: "untranslated lines are kept",
+			script: `var jsonData = pm.response.json();
+console.log(jsonData);
+pm.test("Body matches string", function () {
+    pm.expect(pm.response.text()).to.include("string_you_want_to_search");
+    pm.expect(jsonData.total).to.not.have.lengthOf(2);
+    pm.expect(pm.response.code).to.not.eql(500);
+    pm.expect(jsonData.when).to.satisfy(isRecent);
+    pm.environment.set("stamp", Date.now());
+});`,
+			untranslated: []string{
+				"console.log(jsonData);",
+				`pm.expect(pm.response.text()).to.include("string_you_want_to_search");`,
+				"pm.expect(jsonData.total).to.not.have.lengthOf(2);",
+				"pm.expect(pm.response.code).to.not.eql(500);",
+				"pm.expect(jsonData.when).to.satisfy(isRecent);",
+				`pm.environment.set("stamp", Date.now());`,
+			},
+		},
+		{
+			name: "comments and blank lines are boilerplate",
+			script: `// checks generated by Postman
+
+pm.test("ok", function () {
+    // the status
+    pm.response.to.have.status(200);
+});`,
+			status: 200,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := translatePostmanTests(strings.Split(test.script, "\n"))
+			if got.status != test.status {
+				t.Errorf("status = %d, want %d", got.status, test.status)
+			}
+			if !reflect.DeepEqual(validators(got.validators), test.validators) {
+				t.Errorf("validators = %q, want %q", validators(got.validators), test.validators)
+			}
+			if test.capture == nil {
+				test.capture = map[string]string{}
+			}
+			if !reflect.DeepEqual(got.capture, test.capture) {
+				t.Errorf("capture = %v, want %v", got.capture, test.capture)
+			}
+			if got.maxResponseTime != test.maxResponseTime {
+				t.Errorf("maxResponseTime = %q, want %q", got.maxResponseTime, test.maxResponseTime)
+			}
+			if !reflect.DeepEqual(got.untranslated, test.untranslated) {
+				t.Errorf("untranslated = %q, want %q", got.untranslated, test.untranslated)
+			}
+		})
+	}
+}
+
+func TestPostmanScriptParsing(t *testing.T) {
+	closing := []struct {
+		text string
+		open int
+		want int
+	}{
+		{text: `(a)`, open: 0, want: 2},
+		{text: `(f(x), [1, 2])`, open: 0, want: 13},
+		{text: `("a)b", 'c(')`, open: 0, want: 12},
+		{text: `("a\")", x)`, open: 0, want: 10},
+		{text: `(unclosed`, open: 0, want: -1},
+	}
+	for _, test := range closing {
+		if got := closingParen(test.text, test.open); got != test.want {
+			t.Errorf("closingParen(%q) = %d, want %d", test.text, got, test.want)
+		}
+	}
+
+	split := map[string][]string{
+		`"name", jsonData.id`:   {`"name"`, "jsonData.id"},
+		`'a,b', [1, 2], {c: 3}`: {`'a,b'`, "[1, 2]", "{c: 3}"},
+		`fn(1, 2)`:              {"fn(1, 2)"},
+		``:                      {""},
+	}
+	for text, want := range split {
+		if got := splitArgs(text); !reflect.DeepEqual(got, want) {
+			t.Errorf("splitArgs(%q) = %q, want %q", text, got, want)
+		}
+	}
+
+	literals := []struct {
+		text  string
+		value string
+		ok    bool
+	}{
+		{text: `"it's"`, value: "it's", ok: true},
+		{text: `'say \'hi\''`, value: "say 'hi'", ok: true},
+		{text: "`plain`", value: "plain", ok: true},
+		{text: "`id-${id}`", ok: false},
+		{text: ` -1.5e3 `, value: "-1.5e3", ok: true},
+		{text: `null`, value: "null", ok: true},
+		{text: `pm.globals.get("base")`, value: "{{base}}", ok: true},
+		{text: `pm.iterationData.get('row')`, value: "{{row}}", ok: true},
+		{text: `jsonData.id`, ok: false},
+		{text: `"unterminated`, ok: false},
+	}
+	for _, test := range literals {
+		value, ok := jsLiteral(test.text)
+		if value != test.value || ok != test.ok {
+			t.Errorf("jsLiteral(%q) = %q, %v, want %q, %v", test.text, value, ok, test.value, test.ok)
+		}
+	}
+}
@@ -28,32 +28,45 @@ var (
 )
 
 //GetConfigs func
-func GetConfigs(configsFile *string) app.Config {
+// The reports of the init functions are returned with the config, and with
+// the error of the failing one so that the caller can still write them.
+func GetConfigs(configsFile *string) (app.Config, []app.ReportTemplate, error) {
 	log.Info("Loading test configurations ..:)")
 	flag.Parse()
 	if *configsFile == "" {
 		flag.PrintDefaults()
-		os.Exit(1)
+		os.Exit(app.ExitConfig)
 	}
 	if !strings.HasSuffix(*configsFile, "yaml") {
 		log.Println("provide a configuration yaml file.")
-		os.Exit(1)
+		os.Exit(app.ExitConfig)
 	}
-	abs, err := filepath.Abs(*configsFile)
+	config, err := GetAccessToken(loadConfigs(*configsFile))
 	if err != nil {
-		log.Fatalln("Error reading configs file: Cause: ",err)
+		return config, nil, err
+	}
+	return app.RunInitFuncs(config)
+}
+
+// loadConfigs reads the configs file as it is, without fetching the access
+// token or running the init functions.
+func loadConfigs(configsFile string) app.Config {
+	var loaded app.Config
+	abs, err := filepath.Abs(configsFile)
+	if err != nil {
+		app.ExitConfigError("Error reading configs file: Cause: ",err)
 	}
 
 	testData, err := ioutil.ReadFile(abs)
 	if err != nil {
-		log.Fatalln("Error reading configs file: Cause: ",err)
+		app.ExitConfigError("Error reading configs file: Cause: ",err)
 	}
 
-	err = yaml.Unmarshal(testData, &config)
+	err = yaml.Unmarshal(testData, &loaded)
 	if err != nil {
-		log.Fatalln(err)
+		app.ExitConfigError(err)
 	}
-	return GetAccessToken(config)
+	return loaded
 }
 
 //GetScenarios func
@@ -62,38 +75,45 @@ func GetScenarios(scenarioFolder *string) []app.Scenario {
 	flag.Parse()
 	if *scenarioFolder == "" {
 		flag.PrintDefaults()
-		os.Exit(1)
+		os.Exit(app.ExitConfig)
 	}
 	scenarioFolders, err := getWalking(*scenarioFolder)
 	if err != nil {
-		log.Fatal("Error opening folder ", err)
+		app.ExitConfigError("Error opening folder ", err)
 	}
 
 	for _, file := range scenarioFolders {
 		if strings.HasSuffix(file, "yaml") {
 			abs, err := filepath.Abs(file)
 			if err != nil {
-				log.Fatalln("Error reading scenario file: Cause: ",err)
+				app.ExitConfigError("Error reading scenario file: Cause: ",err)
 			}
 			data, err := ioutil.ReadFile(abs)
 			if err != nil {
-				log.Fatalln("Error reading scenario file: Cause: ",err)
+				app.ExitConfigError("Error reading scenario file: Cause: ",err)
 			}
 			err = yaml.Unmarshal(data, &scenarios)
 			if err != nil {
-				log.Fatalln("Error reading scenario file: Cause: ",err)
+				app.ExitConfigError("Error reading scenario file: Cause: ",err)
 			}
 			for i, scenario := range scenarios {
-				scenario.ID = getScenarioID(i)
-				if scenario.Replicas > 0 {
-					for j := 1; j < scenario.Replicas; j++ {
-						scenario.ID = getScenarioID(j)
-						innerScenario := app.Scenario{}
-						copier.Copy(&innerScenario, scenario)
-						allScenarios = append(allScenarios, innerScenario)
+				instances, err := expandExamples(scenario, filepath.Dir(abs))
+				if err != nil {
+					app.ExitConfigError("Error reading scenario examples: Cause: ", err)
+				}
+				for _, instance := range instances {
+					instance.ID = getScenarioID(i, instance.Row)
+					instance.Dir = filepath.Dir(abs)
+					if instance.Replicas > 0 {
+						for j := 1; j < instance.Replicas; j++ {
+							instance.ID = getScenarioID(j, instance.Row)
+							innerScenario := app.Scenario{}
+							copier.Copy(&innerScenario, instance)
+							allScenarios = append(allScenarios, innerScenario)
+						}
 					}
+					allScenarios = append(allScenarios, instance)
 				}
-				allScenarios = append(allScenarios, scenario)
 			}
 		}
 	}
@@ -101,9 +121,12 @@ func GetScenarios(scenarioFolder *string) []app.Scenario {
 }
 
 
-func getScenarioID(id int) string{
+func getScenarioID(id int, row int) string{
 	guid := xid.New()
 	rep := guid.String()
+	if row > 0 {
+		return fmt.Sprintf("SN-%d-row%d-%s", id, row, rep)
+	}
 	return fmt.Sprintf("SN-%d-%s",id,rep)
 }
 
@@ -128,34 +151,64 @@ func getWalking(root string) ([]string, error) {
 }
 
 
-func GetAccessToken(config app.Config) app.Config{
-	if config.InitFunc.Active {
-		log.Println("generating access token")
-		if config.InitFunc.Method != "" {
-			config.InitFunc.Method = strings.ToUpper(config.InitFunc.Method)
+//GetAccessToken runs the init function. With an oauth2 block the token is
+//fetched once to fail fast and then managed per request as the default auth,
+//otherwise the token is fetched once and copied into the shared headers.
+//A failure is returned like the one of an init function.
+func GetAccessToken(config app.Config) (app.Config, error) {
+	if !config.InitFunc.Active {
+		return config, nil
+	}
+	if config.InitFunc.OAuth2.TokenURL != "" {
+		log.Println("generating oauth2 access token")
+		auth := app.Auth{Type: "oauth2", OAuth2: config.InitFunc.OAuth2}.Resolve(config)
+		if _, err := app.OAuth2Token(auth.OAuth2); err != nil {
+			return config, fmt.Errorf("generating access token: %v", err)
 		}
-		req, err := http.NewRequest(config.InitFunc.Method, config.InitFunc.URL, nil)
-		transport := http.DefaultTransport.(*http.Transport).Clone()
-		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
-		client := &http.Client{}
-		client.Transport = transport
-		if err != nil {
-			log.Error(err)
+		if config.Auth.Type == "" {
+			config.Auth = auth
 		}
-		if len(config.InitFunc.Headers) != 0 {
-			for k, v := range config.InitFunc.Headers {
-				req.Header[k] = []string{v}
-			}
-		} else {
-			req.Header["Content-Type"] = []string{"application/json"}
+		return config, nil
+	}
+	log.Println("generating access token")
+	if config.InitFunc.Method != "" {
+		config.InitFunc.Method = strings.ToUpper(config.InitFunc.Method)
+	}
+	req, err := http.NewRequest(config.InitFunc.Method, config.InitFunc.URL, nil)
+	if err != nil {
+		return config, fmt.Errorf("generating access token: %v", err)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	client := &http.Client{}
+	client.Transport = transport
+	if len(config.InitFunc.Headers) != 0 {
+		for k, v := range config.InitFunc.Headers {
+			req.Header[k] = []string{v}
 		}
-		res, err := client.Do(req)
-		defer res.Body.Close()
-		body, err := ioutil.ReadAll(res.Body)
-		tkn := gjson.Get(string(body), config.InitFunc.GetValue).String()
-		accessToken := fmt.Sprintf("Bearer %s", tkn)
-		config.Headers[config.InitFunc.TargetValue] = accessToken
-		return config
-	}
-	return config
+	} else {
+		req.Header["Content-Type"] = []string{"application/json"}
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return config, fmt.Errorf("generating access token: %v", err)
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return config, fmt.Errorf("generating access token: %v", err)
+	}
+	if res.StatusCode/100 != 2 {
+		return config, fmt.Errorf("generating access token: status %d: %s", res.StatusCode, body)
+	}
+	tkn := gjson.Get(string(body), config.InitFunc.GetValue)
+	if !tkn.Exists() {
+		return config, fmt.Errorf("generating access token: no value at '%s' in %s", config.InitFunc.GetValue, body)
+	}
+	accessToken := fmt.Sprintf("Bearer %s", tkn.String())
+	if config.Headers == nil {
+		config.Headers = map[string]string{}
+	}
+	config.Headers[config.InitFunc.TargetValue] = accessToken
+	return config, nil
 }
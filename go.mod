@@ -7,11 +7,11 @@ require (
 	github.com/basgys/goxml2json v1.1.0
 	github.com/bitly/go-simplejson v0.5.0 // indirect
 	github.com/bmizerany/assert v0.0.0-20160611221934-b7ed37b82869 // indirect
-	github.com/hashicorp/go-retryablehttp v0.6.7
 	github.com/jinzhu/copier v0.0.0-20190924061706-b57f9002281a
 	github.com/kyokomi/emoji/v2 v2.2.8
 	github.com/olekukonko/tablewriter v0.0.4
 	github.com/rs/xid v1.2.1
+	github.com/santhosh-tekuri/jsonschema/v5 v5.2.0
 	github.com/satori/go.uuid v1.2.0
 	github.com/segmentio/kafka-go v0.4.2
 	github.com/sirupsen/logrus v1.6.0
@@ -10,6 +10,8 @@ import (
 	uuid "github.com/satori/go.uuid"
 	log "github.com/sirupsen/logrus"
 	"os"
+	"runtime"
+	"strings"
 	"time"
 )
 
@@ -18,29 +20,71 @@ var (
 	scenarioPath *string
 	scenarios    []app.Scenario
 	config app.Config
+	setupReports []app.ReportTemplate
+	setupErr  error
 	sessionID string
 	ReportOutput *string
 	verboseMsg *string
+	contract   *string
+	parallel   *int
+	options    app.RunOptions
+	filter     app.Filter
 	runAt time.Time
 )
 
 func init() {
 	_, _ = emoji.Println(":hugging: DASH v.1.0.0 :hugging:")
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		os.Exit(cmd.Execute(os.Args[1:]))
+	}
 	configsPath = flag.String("c", "", "config file")
 	scenarioPath = flag.String("s", "", "scenarios directory/file")
-	ReportOutput = flag.String("o", "", "report output format, supported json, csv, all")
+	ReportOutput = flag.String("o", "", "report output format, supported json, csv, junit, html, har, all (comma separated for several)")
 	verboseMsg = flag.String("v", "", "show a detailed log before writing to other formats")
-	flag.Parse()
+	contract = flag.String("contract", "", "OpenAPI 3 or Swagger 2 spec to check every response against")
+	parallel = flag.Int("parallel", runtime.NumCPU(), "number of scenarios to run concurrently")
+	flag.BoolVar(&options.FailFast, "fail-fast", false, "stop the run after the first failed scenario")
+	flag.IntVar(&options.MaxFailures, "max-failures", 0, "stop the run after this many failed scenarios")
+	flag.StringVar(&filter.Service, "service", "", "only run scenarios of these services, e.g. users,orders or !legacy")
+	flag.StringVar(&filter.Severity, "severity", "", "only run scenarios of these severities, e.g. critical,high")
+	flag.StringVar(&filter.Priority, "priority", "", "only run scenarios of these priorities")
+	flag.StringVar(&filter.Labels, "labels", "", "only run scenarios with these labels, e.g. smoke or 'regression && !slow'")
+	flag.StringVar(&filter.Name, "name", "", "only run scenarios whose name matches this regex, prefix with ! to exclude")
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	if err := flag.CommandLine.Parse(os.Args[1:]); err == flag.ErrHelp {
+		os.Exit(app.ExitPassed)
+	} else if err != nil {
+		os.Exit(app.ExitConfig)
+	}
 
 	if *configsPath == "" || *scenarioPath == "" {
 		flag.PrintDefaults()
-		os.Exit(1)
+		os.Exit(app.ExitConfig)
 	}
 	if *ReportOutput == ""{
 		log.Info("No output format passed, therefore ignored.")
 	}
-	config = cmd.GetConfigs(configsPath)
 	scenarios = cmd.GetScenarios(scenarioPath)
+	if *contract != "" {
+		if err := app.LoadContract(*contract); err != nil {
+			app.ExitConfigError("Error loading the contract: Cause: ", err)
+		}
+	}
+	var err error
+	scenarios, err = app.FilterScenarios(scenarios, filter)
+	if err != nil {
+		app.ExitConfigError("Error filtering scenarios: Cause: ", err)
+	}
+	if len(scenarios) == 0 {
+		app.ExitConfigError("No scenarios match the filters.")
+	}
+	// the suite is checked before the init functions create any test data
+	if err := app.CheckDependencies(scenarios); err != nil {
+		app.ExitConfigError("Invalid scenario dependencies: Cause: ", err)
+	}
+	app.CancelOnInterrupt()
+	app.RecordHAR(*ReportOutput)
+	config, setupReports, setupErr = cmd.GetConfigs(configsPath)
 
 	u := uuid.NewV4()
 	runAt = time.Now()
@@ -53,8 +97,17 @@ func main() {
 	log.Info("Running Tests!")
 	_, _ = emoji.Println(":gear::gear::gear: Running Tests! :gear::gear::gear:")
 	fmt.Println("Test outcome >>> see results.json // results.csv for a detailed report. >>>")
-	app.Commander(totalScenarios,finalScenarios,sessionID,ReportOutput,verboseMsg,scenarios, config)
+	options.Parallel = *parallel
+	if setupErr != nil {
+		// no scenario runs, but the setup reports are written and teardown
+		// cleans up what the steps before the failing one created
+		log.Errorln("Error running init functions: Cause: ", setupErr)
+		app.Commander(0, finalScenarios, sessionID, ReportOutput, verboseMsg, nil, config, setupReports, options)
+		os.Exit(app.ExitErrors)
+	}
+	summary := app.Commander(totalScenarios,finalScenarios,sessionID,ReportOutput,verboseMsg,scenarios, config, setupReports, options)
 	_, _ = emoji.Println("Testing completed!! :hourglass:")
-	fmt.Printf("Run %d in %s ", totalScenarios,time.Since(runAt) )
+	fmt.Printf("Run %d in %s: %s\n", totalScenarios,time.Since(runAt), summary)
+	os.Exit(summary.ExitCode())
 }
 
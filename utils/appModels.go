@@ -5,7 +5,6 @@ import (
 	"compress/gzip"
 	"crypto/tls"
 	"fmt"
-	"github.com/hashicorp/go-retryablehttp"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 	_ "github.com/tidwall/gjson"
@@ -31,11 +30,21 @@ type Scenario struct {
 	ID            string
 	Scenario      string
 	Replicas      int
-	Examples      string
+	Examples      interface{}
 	ExamplesData  interface{}
+	Row           int `yaml:"-"`
+	ExampleRow    map[string]string `yaml:"-"`
+	Dir           string `yaml:"-"`
 	Severity      string
 	Priority      string
+	Labels        []string
 	Delay         int
+	Timeout       string
+	MaxResponseTime string `yaml:"max_response_time"`
+	Retry         Retry
+	Attempts      int `yaml:"-"`
+	Poll          Poll
+	Polls         int `yaml:"-"`
 	Tag           string
 	Service       string
 	Status        int
@@ -57,6 +66,11 @@ type Scenario struct {
 	Validators    []struct {
 		Validate Validate
 	}
+	Capture         map[string]string
+	DependsOn       []string `yaml:"depends_on"`
+	Before          []InitFunc
+	After           []InitFunc
+	HookReports     []ReportTemplate `yaml:"-"`
 	ErrorOutcome    *ErrorOutcome
 	ValidateOutcome *ValidateOutcome
 	Response        *Response
@@ -65,6 +79,7 @@ type Scenario struct {
 }
 
 type ReportTemplate struct {
+	Phase                 string  `json:"phase"`
 	Scenario              string  `json:"scenario"`
 	ID             		string`json:"scenario_id"`
 	Tag                   string  `json:"tag"`
@@ -72,6 +87,7 @@ type ReportTemplate struct {
 	Status                int     `json:"status"`
 	Severity              string  `json:"severity"`
 	Priority              string  `json:"priority"`
+	Labels                string  `json:"labels"`
 	Url                   string  `json:"url"`
 	Method                string  `json:"method"`
 	Body                  string  `json:"request_body"`
@@ -83,34 +99,58 @@ type ReportTemplate struct {
 	Validators            string  `json:"validators"`
 	RunID                 string  `json:"run_id"`
 	ExecutionTime         string  `json:"execution_time"`
+	ErrorReason           string  `json:"error_reason"`
 	ErrorDescription      string  `json:"error_description"`
 	ResponseCode          int     `json:"response_code"`
 	ResponseBody          string  `json:"response_body"`
 	ResponseTime          float64 `json:"response_time"`
+	Attempts              int     `json:"attempts"`
+	Polls                 int     `json:"polls"`
 	PassCount             int     `json:"total_pass"`
 	FailedCount           int     `json:"total_fail"`
 	ValidationDescription string  `json:"validation_description"`
+	Warnings              string  `json:"warnings"`
+	Curl                  string  `json:"curl"`
 	FinalTestStatus       string  `json:"outcome"`
 	Developer             string  `json:"developer"`
 	Tester                string  `json:"tester"`
 }
 
 // Validate struct
+// Type selects what is validated: the response body (default), the whole
+// body against the JSON Schema in Schema, a response header or a Set-Cookie
+// cookie. For header and cookie Extract is the header or cookie name, and
+// Attribute picks a cookie attribute instead of its value.
 type Validate struct {
+	Type       string
 	Extract    string
 	Comparator string
 	Expected   string
+	Schema     interface{}
+	Attribute  string
 }
 
 // Auth struct
+// Type is one of basic, bearer, apikey, digest or oauth2. Values is the short
+// form of the credentials ("user:password", a token or a key); the named
+// fields take precedence. Any value may be a {{data}} reference, env:NAME or
+// file:path.
 type Auth struct {
-	Type   string
-	Values string
+	Type     string
+	Values   string
+	Username string
+	Password string
+	Token    string
+	Key      string
+	In       string
+	OAuth2   OAuth2
 }
 
 // Services struct
 type Services struct {
 	Name      string
+	Timeout   string
+	Retry     Retry
 	Auth      Auth
 	Method    string
 	Tag       string
@@ -125,9 +165,14 @@ type Config struct {
 	Services     []Services
 	Data         map[string]string
 	Headers      map[string]string
+	Auth         Auth
+	Timeout      string
+	Retry        Retry
 	Metadata     Metadata
 	MaskedFields map[string]string
 	InitFunc InitFunc
+	InitFuncs    []InitFunc
+	Teardown     []InitFunc
 }
 
 
@@ -137,6 +182,7 @@ type Metadata struct {
 	Collection  string
 	Domain      string
 	Stream bool
+	StreamScenarios bool
 }
 
 // ErrorType struct
@@ -158,6 +204,7 @@ type ValidateOutcome struct {
 	Failed      int
 	FinalStatus string
 	Actual      string
+	Warnings    []string
 }
 
 type Response struct {
@@ -166,8 +213,13 @@ type Response struct {
 	Time   float64
 }
 
+// InitFunc struct
+// The single initfunc fetches an access token into the shared headers. Each
+// of the initfuncs steps runs an http call, a shell command or reads a file
+// and extracts values into config.Data.
 type InitFunc struct {
 	Active bool
+	Name   string
 	Action string
 	Alias string
 	GetValue string
@@ -175,20 +227,21 @@ type InitFunc struct {
 	URL string
 	Headers map[string]string
 	Method string
+	Body    string
+	Command string
+	Path    string
+	Extract map[string]string
+	OAuth2 OAuth2
 }
 
 
 var (
-	appConfig AppConfig
+	appConfig        AppConfig
 	defaultTransport *http.Transport
-	proxyTransport *http.Transport
-	client *http.Client
-	request    *http.Request
-	err        ErrorType
-	bodyBuffer *bytes.Buffer
-	res        Response
-	reader     io.Reader
-	digitCheck = regexp.MustCompile(`^[0-9]+$`)
+	proxyTransport   *http.Transport
+	defaultClient    *http.Client
+	proxyClient      *http.Client
+	digitCheck       = regexp.MustCompile(`^[0-9]+$`)
 )
 
 func init(){
@@ -199,14 +252,14 @@ func init(){
 
 	if err := viper.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-			log.Fatal("Unable to read the app config file.")
+			ExitConfigError("Unable to read the app config file.")
 		} else {
-			log.Fatalf("\"Unable to read the app config file. Error=%v", err)
+			ExitConfigError(fmt.Sprintf("\"Unable to read the app config file. Error=%v", err))
 		}
 	}
 	err := viper.Unmarshal(&appConfig)
 	if err != nil {
-		log.Fatalf("Unable to decode the contents of config file, Error= %v",err)
+		ExitConfigError(fmt.Sprintf("Unable to decode the contents of config file, Error= %v",err))
 	}
 
 	defaultTransport = &http.Transport{
@@ -223,13 +276,29 @@ func init(){
 			TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
 		proxyTransport=innerTransport
 	}
-	c := retryablehttp.NewClient()
-	c.RetryMax = 2
-	client = c.StandardClient()
+	defaultClient = newClient(defaultTransport)
+	proxyClient = defaultClient
+	if proxyTransport != nil {
+		proxyClient = newClient(proxyTransport)
+	}
+}
+
+// newClient builds a client bound to a single transport. Clients are shared
+// by all workers, so they must never be mutated once created. Retries are
+// up to the scenario's retry policy, see sendWithRetry. The har transport
+// records the exchanges when -o har is on.
+func newClient(transport *http.Transport) *http.Client {
+	return &http.Client{Transport: &harTransport{transport: transport}}
 }
 
 
 func (scenario *Scenario) Request() {
+	var (
+		client     *http.Client
+		request    *http.Request
+		err        ErrorType
+		bodyBuffer *bytes.Buffer
+	)
 	if scenario.Method != "" {
 		scenario.Method = strings.ToUpper(scenario.Method)
 	}
@@ -243,11 +312,11 @@ func (scenario *Scenario) Request() {
 		digitCheck.MatchString(serviceURL.Hostname()[1:])
 	}
 	if stringInSlice(serviceURL.Hostname(), strings.Split(appConfig.NoProxy, ",")) == true {
-		client.Transport=defaultTransport
-	} else if appConfig.Proxy != ""{
-		client.Transport=proxyTransport
-	}else{
-		client.Transport=defaultTransport
+		client = defaultClient
+	} else if appConfig.Proxy != "" {
+		client = proxyClient
+	} else {
+		client = defaultClient
 	}
 	reqUrl, err := url.Parse(scenario.Url)
 	if err != nil {
@@ -285,49 +354,17 @@ func (scenario *Scenario) Request() {
 	} else {
 		request.Header["Content-Type"] = []string{"application/json"}
 	}
-	start := time.Now()
 	if scenario.Delay != 0{
 		time.Sleep(time.Duration(scenario.Delay)*time.Second)
 	}
-	response, err := client.Do(request)
-	stop := time.Since(start)
-	//MaskHeaders(scenario)
-	if err != nil {
-		errorReporter(err, scenario)
-		return
-	}
-	if response != nil {
-		resHeader := response.Header.Get("Content-Encoding")
-		if resHeader == "gzip" {
-			reader, err = gzip.NewReader(response.Body)
-			if err != nil {
-				errorReporter(err, scenario)
-				return
-			}
-		} else {
-			reader = response.Body
-		}
-		defer response.Body.Close()
-	}
-	body, err := ioutil.ReadAll(reader)
-	if err != nil {
-		errorReporter(err, scenario)
-		return
-	}
-	res.Body = string(body)
-	res.Status = response.StatusCode
-	res.Time = stop.Seconds()
-	scenario.Response = &res
-	validator(scenario, response, string(body))
+	scenario.poll(client, request)
 }
 func (scenario *Scenario) UrlEncodedRequest() {
 	var (
 		request *http.Request
 		err     ErrorType
-		res     Response
-		reader  io.Reader
 	)
-	client.Transport=defaultTransport
+	client := defaultClient
 	if scenario.Method != "" {
 		scenario.Method = strings.ToUpper(scenario.Method)
 	}
@@ -359,13 +396,33 @@ func (scenario *Scenario) UrlEncodedRequest() {
 	} else {
 		request.Header.Add("Content-Type", "application/json")
 	}
-	start := time.Now()
 	if scenario.Delay != 0{
 		time.Sleep(time.Duration(scenario.Delay)*time.Second)
 	}
-	response, err := client.Do(request)
-	stop := time.Since(start)
+	scenario.poll(client, request)
+}
 
+// send makes one request and validates its response.
+func (scenario *Scenario) send(client *http.Client, request *http.Request) {
+	var (
+		res    Response
+		reader io.Reader
+	)
+	ctx, cancel, err := scenario.requestContext()
+	if err != nil {
+		errorReporterWithReason(err, scenario, "Invalid timeout")
+		return
+	}
+	defer cancel()
+	request = request.WithContext(withHARScenario(ctx, scenario))
+	start := time.Now()
+	response, err := scenario.do(client, request)
+	stop := time.Since(start)
+	//MaskHeaders(scenario)
+	if err != nil && runCancelled() {
+		cancelScenario(scenario)
+		return
+	}
 	if err != nil {
 		errorReporter(err, scenario)
 		return
@@ -392,9 +449,7 @@ func (scenario *Scenario) UrlEncodedRequest() {
 		res.Body = "null"
 	} else {
 		res.Body = string(body)
-
 	}
-
 	res.Status = response.StatusCode
 	res.Time = stop.Seconds()
 	scenario.Response = &res
@@ -402,10 +457,52 @@ func (scenario *Scenario) UrlEncodedRequest() {
 }
 
 
+// do sends the request with the scenario's auth applied, answering a digest
+// challenge or an expired oauth2 token with a second request when needed.
+// Failed attempts are retried according to the scenario's retry policy.
+func (scenario *Scenario) do(client *http.Client, request *http.Request) (*http.Response, error) {
+	if err := applyAuth(scenario.Auth, request, client); err != nil {
+		return nil, fmt.Errorf("applying %s auth: %v", scenario.Auth.Type, err)
+	}
+	return scenario.sendWithRetry(request, func(request *http.Request) (*http.Response, error) {
+		response, err := client.Do(request)
+		if err != nil {
+			return nil, err
+		}
+		retry, err := renewOAuth2Token(scenario.Auth, request, response, client)
+		if err != nil {
+			response.Body.Close()
+			return nil, fmt.Errorf("renewing oauth2 token: %v", err)
+		}
+		if retry != nil {
+			response.Body.Close()
+			return client.Do(retry)
+		}
+		retry, err = respondToDigest(scenario.Auth, request, response)
+		if err != nil {
+			response.Body.Close()
+			return nil, fmt.Errorf("answering digest challenge: %v", err)
+		}
+		if retry != nil {
+			response.Body.Close()
+			return client.Do(retry)
+		}
+		return response, nil
+	})
+}
+
 func errorReporter(err error, scenario *Scenario) {
+	if isTimeout(err) {
+		errorReporterWithReason(fmt.Errorf("no response within %s: %v", scenario.Timeout, err), scenario, "Request timed out")
+		return
+	}
+	errorReporterWithReason(err, scenario, "Error parsing response body")
+}
+
+func errorReporterWithReason(err error, scenario *Scenario, reason string) {
 	var errOutcome ErrorOutcome
 	errOutcome.ErrorDesc = err.Error()
-	errOutcome.Reason = "Error parsing response body"
+	errOutcome.Reason = reason
 	scenario.ErrorOutcome = &errOutcome
 }
 
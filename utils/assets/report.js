@@ -0,0 +1,155 @@
+(function () {
+  var statuses = ["passed", "failed", "error", "skipped", "cancelled"];
+  var colors = { passed: "#28a745", failed: "#dc3545", error: "#fd7e14", skipped: "#6c757d", cancelled: "#adb5bd" };
+  var reports = report || [];
+
+  function el(tag, attrs, children) {
+    var node = document.createElement(tag);
+    Object.keys(attrs || {}).forEach(function (k) {
+      if (k === "text") { node.textContent = attrs[k]; } else { node.setAttribute(k, attrs[k]); }
+    });
+    (children || []).forEach(function (c) { if (c) { node.appendChild(c); } });
+    return node;
+  }
+  function count(list) {
+    var totals = { passed: 0, failed: 0, error: 0, skipped: 0, cancelled: 0 };
+    list.forEach(function (r) { totals[r.outcome] = (totals[r.outcome] || 0) + 1; });
+    return totals;
+  }
+  function pct(n, total) { return total ? (100 * n / total).toFixed(1) + "%" : "0%"; }
+
+  function donut() {
+    var tests = reports.filter(function (r) { return r.phase === "test"; });
+    var totals = count(tests);
+    var svg = document.getElementById("donut");
+    var ns = "http://www.w3.org/2000/svg";
+    var offset = 25;
+    var base = document.createElementNS(ns, "circle");
+    base.setAttribute("cx", "21"); base.setAttribute("cy", "21"); base.setAttribute("r", "15.915");
+    base.setAttribute("fill", "transparent"); base.setAttribute("stroke", "#e9ecef"); base.setAttribute("stroke-width", "6");
+    svg.appendChild(base);
+    statuses.forEach(function (s) {
+      var share = tests.length ? 100 * totals[s] / tests.length : 0;
+      if (share > 0) {
+        var c = document.createElementNS(ns, "circle");
+        c.setAttribute("cx", "21"); c.setAttribute("cy", "21"); c.setAttribute("r", "15.915");
+        c.setAttribute("fill", "transparent"); c.setAttribute("stroke", colors[s]); c.setAttribute("stroke-width", "6");
+        c.setAttribute("stroke-dasharray", share + " " + (100 - share));
+        c.setAttribute("stroke-dashoffset", String(offset));
+        svg.appendChild(c);
+        offset = (offset - share + 100) % 100;
+      }
+      document.getElementById("legend").appendChild(el("li", {}, [el("span", { "class": "swatch " + s }), document.createTextNode(s + ": " + totals[s])]));
+    });
+    var text = document.createElementNS(ns, "text");
+    text.setAttribute("x", "21"); text.setAttribute("y", "23"); text.setAttribute("text-anchor", "middle"); text.setAttribute("font-size", "6");
+    text.textContent = tests.length;
+    svg.appendChild(text);
+
+    var list = document.getElementById("totals");
+    [["Passed", totals.passed], ["Failed", totals.failed], ["Error(s)", totals.error], ["Skipped", totals.skipped], ["Cancelled", totals.cancelled]].forEach(function (t) {
+      list.appendChild(el("li", {}, [document.createTextNode(t[0] + ": "), el("span", { text: String(t[1]) })]));
+    });
+    [["Pass Rate", totals.passed], ["Failure Rate", totals.failed], ["Error Rate", totals.error]].forEach(function (t) {
+      list.appendChild(el("li", {}, [document.createTextNode(t[0] + ": "), el("span", { text: pct(t[1], tests.length) })]));
+    });
+  }
+
+  function bars() {
+    var byService = {};
+    reports.filter(function (r) { return r.phase === "test"; }).forEach(function (r) {
+      (byService[r.service || "default"] = byService[r.service || "default"] || []).push(r);
+    });
+    var holder = document.getElementById("bars");
+    Object.keys(byService).sort().forEach(function (name) {
+      var list = byService[name], totals = count(list);
+      var bar = el("div", { "class": "bar" });
+      statuses.forEach(function (s) {
+        if (totals[s]) { bar.appendChild(el("span", { "class": s, style: "width:" + pct(totals[s], list.length), title: s + ": " + totals[s] })); }
+      });
+      holder.appendChild(el("div", { "class": "bar-row" }, [el("div", { "class": "bar-label", text: name, title: name }), bar, el("div", { "class": "bar-count", text: totals.passed + "/" + list.length })]));
+    });
+  }
+
+  function kv(rows) {
+    var table = el("table", { "class": "kv" });
+    rows.forEach(function (row) {
+      if (row[1] !== undefined && row[1] !== "") { table.appendChild(el("tr", {}, [el("td", { text: row[0] }), el("td", { text: String(row[1]) })])); }
+    });
+    return table;
+  }
+  function checks(description) {
+    var list = el("ul", { "class": "checks" });
+    (description || "").split("\n").forEach(function (line) {
+      if (!line.trim()) { return; }
+      var kind = /^Passed/.test(line) ? "ok" : /^Failed/.test(line) ? "ko" : /^Warning/.test(line) ? "warn" : "info";
+      list.appendChild(el("li", { "class": kind, text: line }));
+    });
+    return list;
+  }
+  function pretty(text) {
+    if (!text || text === "null") { return text; }
+    try { return JSON.stringify(JSON.parse(text.replace(/'/g, "\"")), null, 2); } catch (e) { return text; }
+  }
+
+  function scenario(r) {
+    var details = el("details", { "class": "status-" + r.outcome });
+    details.dataset.service = r.service || "";
+    details.dataset.tag = r.tag || "";
+    details.dataset.status = r.outcome || "";
+    details.dataset.phase = r.phase || "";
+    details.dataset.text = [r.scenario, r.url, r.scenario_id].join(" ").toLowerCase();
+    details.appendChild(el("summary", {}, [
+      el("span", { "class": "badge " + r.outcome, text: r.outcome }),
+      el("span", { "class": "name", text: r.scenario }),
+      el("span", { "class": "meta", text: [r.phase !== "test" ? r.phase : "", r.service, r.method, r.url].filter(Boolean).join(" · ") }),
+      el("span", { "class": "meta", text: r.response_code + " · " + (r.response_time * 1000).toFixed(0) + " ms" })
+    ]));
+    var headers = r.headers;
+    details.appendChild(el("div", { "class": "detail" }, [
+      el("div", {}, [el("h3", { text: "Request" }), kv([["id", r.scenario_id], ["method", r.method], ["url", r.url], ["tag", r.tag], ["severity", r.severity], ["priority", r.priority], ["developer", r.developer], ["tester", r.tester]]),
+        el("h3", { text: "Headers" }), el("pre", { text: pretty(headers) }),
+        el("h3", { text: "Body" }), el("pre", { text: pretty(r.request_body) || "-" }),
+        r.curl ? el("h3", { text: "curl" }) : null, r.curl ? el("pre", { text: r.curl }) : null]),
+      el("div", {}, [el("h3", { text: "Response" }), kv([["status", r.response_code], ["expected", r.status], ["time", r.response_time + " s"], ["attempts", r.attempts], ["polls", r.polls || ""], ["executed", r.execution_time]]),
+        el("h3", { text: "Body" }), el("pre", { text: pretty(r.response_body) || "-" })]),
+      el("div", { "class": "wide" }, [el("h3", { text: "Validators (" + r.total_pass + " passed, " + r.total_fail + " failed)" }), checks(r.validation_description),
+        r.error_description ? el("h3", { text: "Error" + (r.error_reason ? ": " + r.error_reason : "") }) : null, r.error_description ? el("pre", { text: r.error_description }) : null])
+    ]));
+    return details;
+  }
+
+  function options(id, field) {
+    var select = document.getElementById(id), seen = {};
+    reports.forEach(function (r) { if (r[field]) { seen[r[field]] = true; } });
+    Object.keys(seen).sort().forEach(function (v) { select.appendChild(el("option", { value: v, text: v })); });
+    select.addEventListener("change", filter);
+  }
+  function filter() {
+    var service = document.getElementById("filter-service").value;
+    var tag = document.getElementById("filter-tag").value;
+    var status = document.getElementById("filter-status").value;
+    var phase = document.getElementById("filter-phase").value;
+    var text = document.getElementById("filter-text").value.toLowerCase();
+    var shown = 0, all = document.querySelectorAll("#scenarios details");
+    Array.prototype.forEach.call(all, function (d) {
+      var visible = (!service || d.dataset.service === service) && (!tag || d.dataset.tag === tag) &&
+        (!status || d.dataset.status === status) && (!phase || d.dataset.phase === phase) &&
+        (!text || d.dataset.text.indexOf(text) >= 0);
+      d.style.display = visible ? "" : "none";
+      if (visible) { shown++; }
+    });
+    document.getElementById("shown").textContent = shown + " of " + all.length + " shown";
+  }
+
+  donut();
+  bars();
+  var holder = document.getElementById("scenarios");
+  reports.forEach(function (r) { holder.appendChild(scenario(r)); });
+  options("filter-service", "service");
+  options("filter-tag", "tag");
+  options("filter-status", "outcome");
+  options("filter-phase", "phase");
+  document.getElementById("filter-text").addEventListener("input", filter);
+  filter();
+})();
@@ -0,0 +1,284 @@
+package dash
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Resolve returns a copy of the auth whose {{name}} references are replaced
+// from config.Data.
+func (auth Auth) Resolve(config Config) Auth {
+	fields := []*string{&auth.Values, &auth.Username, &auth.Password, &auth.Token,
+		&auth.OAuth2.TokenURL, &auth.OAuth2.ClientID, &auth.OAuth2.ClientSecret, &auth.OAuth2.Username,
+		&auth.OAuth2.Password, &auth.OAuth2.Scope, &auth.OAuth2.Audience, &auth.OAuth2.RefreshToken}
+	for _, field := range fields {
+		found := regex.FindAllString(*field, -1)
+		if len(found) != 0 {
+			*field = recurse(found, *field, config)
+		}
+	}
+	return auth
+}
+
+// resolveAuthValue expands the env: and file: prefixes of an auth value.
+// {{name}} references are already replaced from config.Data by Resolve.
+func resolveAuthValue(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, "env:"):
+		name := strings.TrimPrefix(value, "env:")
+		out, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return out, nil
+	case strings.HasPrefix(value, "file:"):
+		out, err := ioutil.ReadFile(strings.TrimPrefix(value, "file:"))
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+	return value, nil
+}
+
+// credentials returns the username and password of a basic or digest auth,
+// falling back to a "user:password" Values string.
+func (auth Auth) credentials() (string, string, error) {
+	username, password := auth.Username, auth.Password
+	if username == "" && auth.Values != "" {
+		values, err := resolveAuthValue(auth.Values)
+		if err != nil {
+			return "", "", err
+		}
+		parts := strings.SplitN(values, ":", 2)
+		username = parts[0]
+		if len(parts) == 2 {
+			password = parts[1]
+		}
+		return username, password, nil
+	}
+	username, err := resolveAuthValue(username)
+	if err != nil {
+		return "", "", err
+	}
+	password, err = resolveAuthValue(password)
+	return username, password, err
+}
+
+// secret returns the token of a bearer auth or the key of an apikey auth.
+func (auth Auth) secret() (string, error) {
+	if auth.Token != "" {
+		return resolveAuthValue(auth.Token)
+	}
+	return resolveAuthValue(auth.Values)
+}
+
+// applyAuth sets the credentials of every auth type that is sent up front.
+// Digest is answered in respondToDigest once the server has sent a challenge.
+// An oauth2 token is requested with the client and context of the request.
+func applyAuth(auth Auth, request *http.Request, client *http.Client) error {
+	switch strings.ToLower(auth.Type) {
+	case "", "none", "digest":
+		return nil
+	case "basic":
+		username, password, err := auth.credentials()
+		if err != nil {
+			return err
+		}
+		request.SetBasicAuth(username, password)
+	case "bearer":
+		token, err := auth.secret()
+		if err != nil {
+			return err
+		}
+		request.Header.Set("Authorization", "Bearer "+token)
+	case "oauth2":
+		token, err := providerFor(auth.OAuth2).token(request.Context(), client)
+		if err != nil {
+			return err
+		}
+		request.Header.Set("Authorization", "Bearer "+token)
+	case "apikey":
+		key, err := auth.secret()
+		if err != nil {
+			return err
+		}
+		name := auth.Key
+		if strings.ToLower(auth.In) == "query" {
+			if name == "" {
+				name = "api_key"
+			}
+			query := request.URL.Query()
+			query.Set(name, key)
+			request.URL.RawQuery = query.Encode()
+			return nil
+		}
+		if name == "" {
+			name = "X-API-Key"
+		}
+		request.Header.Set(name, key)
+	default:
+		return fmt.Errorf("unsupported auth type %s", auth.Type)
+	}
+	return nil
+}
+
+// renewOAuth2Token builds the retry of a request whose oauth2 token was
+// rejected with a 401, after dropping that token from the cache.
+func renewOAuth2Token(auth Auth, request *http.Request, response *http.Response, client *http.Client) (*http.Request, error) {
+	if strings.ToLower(auth.Type) != "oauth2" || response.StatusCode != http.StatusUnauthorized {
+		return nil, nil
+	}
+	provider := providerFor(auth.OAuth2)
+	provider.invalidate(strings.TrimPrefix(request.Header.Get("Authorization"), "Bearer "))
+	token, err := provider.token(request.Context(), client)
+	if err != nil {
+		return nil, err
+	}
+	retry, err := cloneRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	retry.Header.Set("Authorization", "Bearer "+token)
+	return retry, nil
+}
+
+// respondToDigest builds the retry of a request that was answered with a
+// digest challenge. It returns nil when the response is not such a challenge.
+func respondToDigest(auth Auth, request *http.Request, response *http.Response) (*http.Request, error) {
+	if strings.ToLower(auth.Type) != "digest" || response.StatusCode != http.StatusUnauthorized {
+		return nil, nil
+	}
+	challenge := response.Header.Get("WWW-Authenticate")
+	if !strings.HasPrefix(strings.ToLower(challenge), "digest ") {
+		return nil, nil
+	}
+	username, password, err := auth.credentials()
+	if err != nil {
+		return nil, err
+	}
+	authorization, err := digestAuthorization(challenge, username, password, request.Method, request.URL.RequestURI())
+	if err != nil {
+		return nil, err
+	}
+	retry, err := cloneRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	retry.Header.Set("Authorization", authorization)
+	return retry, nil
+}
+
+// cloneRequest copies a request so it can be sent again, body included.
+func cloneRequest(request *http.Request) (*http.Request, error) {
+	retry := request.Clone(request.Context())
+	if request.GetBody != nil {
+		body, err := request.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retry.Body = body
+	}
+	return retry, nil
+}
+
+func digestAuthorization(challenge, username, password, method, uri string) (string, error) {
+	params := parseDigestChallenge(challenge)
+	algorithm := params["algorithm"]
+	var newHash func() hash.Hash
+	switch strings.ToUpper(strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS")) {
+	case "", "MD5":
+		newHash = md5.New
+	case "SHA-256":
+		newHash = sha256.New
+	default:
+		return "", fmt.Errorf("unsupported digest algorithm %s", algorithm)
+	}
+	digest := func(parts ...string) string {
+		h := newHash()
+		h.Write([]byte(strings.Join(parts, ":")))
+		return hex.EncodeToString(h.Sum(nil))
+	}
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	cnonce := hex.EncodeToString(nonce)
+	nc := "00000001"
+
+	ha1 := digest(username, params["realm"], password)
+	if strings.HasSuffix(strings.ToUpper(algorithm), "-SESS") {
+		ha1 = digest(ha1, params["nonce"], cnonce)
+	}
+	ha2 := digest(method, uri)
+	qop := ""
+	for _, q := range strings.Split(params["qop"], ",") {
+		if strings.TrimSpace(q) == "auth" {
+			qop = "auth"
+		}
+	}
+	var response string
+	if qop == "" {
+		response = digest(ha1, params["nonce"], ha2)
+	} else {
+		response = digest(ha1, params["nonce"], nc, cnonce, qop, ha2)
+	}
+
+	fields := []string{
+		fmt.Sprintf(`username="%s"`, username),
+		fmt.Sprintf(`realm="%s"`, params["realm"]),
+		fmt.Sprintf(`nonce="%s"`, params["nonce"]),
+		fmt.Sprintf(`uri="%s"`, uri),
+		fmt.Sprintf(`response="%s"`, response),
+	}
+	if algorithm != "" {
+		fields = append(fields, "algorithm="+algorithm)
+	}
+	if qop != "" {
+		fields = append(fields, "qop="+qop, "nc="+nc, fmt.Sprintf(`cnonce="%s"`, cnonce))
+	}
+	if opaque, ok := params["opaque"]; ok {
+		fields = append(fields, fmt.Sprintf(`opaque="%s"`, opaque))
+	}
+	return "Digest " + strings.Join(fields, ", "), nil
+}
+
+// parseDigestChallenge splits a WWW-Authenticate digest header into its
+// key/value pairs, honouring commas inside quoted values.
+func parseDigestChallenge(challenge string) map[string]string {
+	params := map[string]string{}
+	rest := strings.TrimSpace(challenge[len("digest "):])
+	for rest != "" {
+		eq := strings.Index(rest, "=")
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = strings.TrimSpace(rest[eq+1:])
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else if comma := strings.Index(rest, ","); comma >= 0 {
+			value, rest = rest[:comma], rest[comma:]
+		} else {
+			value, rest = rest, ""
+		}
+		params[key] = strings.TrimSpace(value)
+		rest = strings.TrimLeft(strings.TrimSpace(rest), ",")
+		rest = strings.TrimSpace(rest)
+	}
+	return params
+}
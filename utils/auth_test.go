@@ -0,0 +1,240 @@
+package dash
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyAuth(t *testing.T) {
+	os.Setenv("DASH_TEST_PASSWORD", "s3cret:with:colons")
+	defer os.Unsetenv("DASH_TEST_PASSWORD")
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("file-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		auth   Auth
+		header string
+		value  string
+		query  string
+		err    string
+	}{
+		{name: "no auth", auth: Auth{}, header: "Authorization"},
+		{name: "basic from values", auth: Auth{Type: "basic", Values: "jane:pa:ss"}, header: "Authorization", value: "Basic amFuZTpwYTpzcw=="},
+		{name: "basic fields win over values", auth: Auth{Type: "Basic", Values: "x:y", Username: "jane", Password: "env:DASH_TEST_PASSWORD"}, header: "Authorization", value: "Basic amFuZTpzM2NyZXQ6d2l0aDpjb2xvbnM="},
+		{name: "bearer token from a file", auth: Auth{Type: "bearer", Token: "file:" + tokenFile}, header: "Authorization", value: "Bearer file-token"},
+		{name: "bearer short form", auth: Auth{Type: "bearer", Values: "abc"}, header: "Authorization", value: "Bearer abc"},
+		{name: "apikey default header", auth: Auth{Type: "apikey", Values: "k1"}, header: "X-API-Key", value: "k1"},
+		{name: "apikey named header", auth: Auth{Type: "apikey", Key: "X-Token", Values: "k2"}, header: "X-Token", value: "k2"},
+		{name: "apikey default query name", auth: Auth{Type: "apikey", In: "query", Values: "k 3"}, query: "api_key=k+3&page=2"},
+		{name: "apikey named query", auth: Auth{Type: "apikey", In: "Query", Key: "key", Values: "k4"}, query: "key=k4&page=2"},
+		{name: "digest waits for the challenge", auth: Auth{Type: "digest", Values: "jane:pass"}, header: "Authorization"},
+		{name: "unset environment variable", auth: Auth{Type: "bearer", Token: "env:DASH_TEST_MISSING"}, err: "environment variable DASH_TEST_MISSING is not set"},
+		{name: "missing file", auth: Auth{Type: "basic", Values: "file:/nonexistent/credentials"}, err: "/nonexistent/credentials"},
+		{name: "unsupported type", auth: Auth{Type: "ntlm"}, err: "unsupported auth type ntlm"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request, _ := http.NewRequest("GET", "http://api.test/users?page=2", nil)
+			err := applyAuth(test.auth, request, http.DefaultClient)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("applyAuth() error = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyAuth() failed: %v", err)
+			}
+			if test.header != "" && request.Header.Get(test.header) != test.value {
+				t.Errorf("%s = %q, want %q", test.header, request.Header.Get(test.header), test.value)
+			}
+			if test.query != "" && request.URL.RawQuery != test.query {
+				t.Errorf("query = %q, want %q", request.URL.RawQuery, test.query)
+			}
+		})
+	}
+}
+
+func TestRespondToDigest(t *testing.T) {
+	challenge := `Digest realm="api", nonce="abc", qop="auth"`
+	tests := []struct {
+		name      string
+		auth      Auth
+		status    int
+		challenge string
+		retried   bool
+	}{
+		{name: "digest challenge", auth: Auth{Type: "digest", Values: "jane:pass"}, status: 401, challenge: challenge, retried: true},
+		{name: "scheme is case insensitive", auth: Auth{Type: "Digest", Values: "jane:pass"}, status: 401, challenge: "DIGEST realm=\"api\", nonce=\"abc\"", retried: true},
+		{name: "basic challenge", auth: Auth{Type: "digest", Values: "jane:pass"}, status: 401, challenge: `Basic realm="api"`},
+		{name: "not a 401", auth: Auth{Type: "digest", Values: "jane:pass"}, status: 403, challenge: challenge},
+		{name: "not a digest auth", auth: Auth{Type: "basic", Values: "jane:pass"}, status: 401, challenge: challenge},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request, _ := http.NewRequest("POST", "http://api.test/orders?id=7", strings.NewReader(`{"qty": 1}`))
+			ioutil.ReadAll(request.Body)
+			response := &http.Response{StatusCode: test.status, Header: http.Header{"Www-Authenticate": []string{test.challenge}}}
+			retry, err := respondToDigest(test.auth, request, response)
+			if err != nil {
+				t.Fatalf("respondToDigest() failed: %v", err)
+			}
+			if (retry != nil) != test.retried {
+				t.Fatalf("respondToDigest() retried = %v, want %v", retry != nil, test.retried)
+			}
+			if retry == nil {
+				return
+			}
+			fields := parseDigestChallenge(retry.Header.Get("Authorization"))
+			if fields["username"] != "jane" || fields["uri"] != "/orders?id=7" {
+				t.Errorf("authorization %v does not answer for jane on the request uri", fields)
+			}
+			if body, _ := ioutil.ReadAll(retry.Body); string(body) != `{"qty": 1}` {
+				t.Errorf("retry body = %q, want the original body", body)
+			}
+		})
+	}
+}
+
+func TestParseDigestChallenge(t *testing.T) {
+	tests := []struct {
+		name      string
+		challenge string
+		want      map[string]string
+	}{
+		{
+			name:      "quoted values",
+			challenge: `Digest realm="testrealm@host.com", qop="auth,auth-int", nonce="dcd98b7102dd2f0e8b11d0f600bfb0c093", opaque="5ccc069c403ebaf9f0171e9517f40e41"`,
+			want: map[string]string{
+				"realm": "testrealm@host.com", "qop": "auth,auth-int",
+				"nonce": "dcd98b7102dd2f0e8b11d0f600bfb0c093", "opaque": "5ccc069c403ebaf9f0171e9517f40e41",
+			},
+		},
+		{
+			name:      "unquoted values and algorithm",
+			challenge: `Digest realm="api", nonce="abc", algorithm=SHA-256, stale=FALSE`,
+			want:      map[string]string{"realm": "api", "nonce": "abc", "algorithm": "SHA-256", "stale": "FALSE"},
+		},
+		{
+			name:      "scheme and keys are case insensitive",
+			challenge: `DIGEST Realm="api", NONCE="abc"`,
+			want:      map[string]string{"realm": "api", "nonce": "abc"},
+		},
+		{
+			name:      "commas and spaces inside quotes",
+			challenge: `Digest realm="a, b = c",nonce="n"`,
+			want:      map[string]string{"realm": "a, b = c", "nonce": "n"},
+		},
+		{
+			name:      "unterminated quote",
+			challenge: `Digest realm="api, nonce=abc`,
+			want:      map[string]string{"realm": "api, nonce=abc"},
+		},
+		{
+			name:      "empty",
+			challenge: `Digest `,
+			want:      map[string]string{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := parseDigestChallenge(test.challenge)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parseDigestChallenge() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDigestAuthorization(t *testing.T) {
+	const (
+		username = "Mufasa"
+		password = "Circle Of Life"
+		method   = "GET"
+		uri      = "/dir/index.html"
+		realm    = "testrealm@host.com"
+		nonce    = "dcd98b7102dd2f0e8b11d0f600bfb0c093"
+	)
+	tests := []struct {
+		name      string
+		algorithm string
+		qop       string
+		newHash   func() hash.Hash
+		session   bool
+		wantQop   string
+	}{
+		{name: "md5 without qop", newHash: md5.New},
+		{name: "md5 with qop auth", algorithm: "MD5", qop: "auth", newHash: md5.New, wantQop: "auth"},
+		{name: "auth picked out of a qop list", qop: "auth-int, auth", newHash: md5.New, wantQop: "auth"},
+		{name: "auth-int only is sent without qop", qop: "auth-int", newHash: md5.New},
+		{name: "md5-sess", algorithm: "MD5-sess", qop: "auth", newHash: md5.New, session: true, wantQop: "auth"},
+		{name: "sha-256", algorithm: "SHA-256", qop: "auth", newHash: sha256.New, wantQop: "auth"},
+		{name: "sha-256-sess", algorithm: "SHA-256-sess", qop: "auth", newHash: sha256.New, session: true, wantQop: "auth"},
+		{name: "lower case algorithm", algorithm: "sha-256", qop: "auth", newHash: sha256.New, wantQop: "auth"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			challenge := `Digest realm="` + realm + `", nonce="` + nonce + `", opaque="xyz"`
+			if test.qop != "" {
+				challenge += `, qop="` + test.qop + `"`
+			}
+			if test.algorithm != "" {
+				challenge += `, algorithm=` + test.algorithm
+			}
+			authorization, err := digestAuthorization(challenge, username, password, method, uri)
+			if err != nil {
+				t.Fatalf("digestAuthorization() failed: %v", err)
+			}
+			if !strings.HasPrefix(authorization, "Digest ") {
+				t.Fatalf("authorization %q does not use the digest scheme", authorization)
+			}
+			fields := parseDigestChallenge(authorization)
+			for key, want := range map[string]string{"username": username, "realm": realm, "nonce": nonce, "uri": uri, "opaque": "xyz", "qop": test.wantQop, "algorithm": test.algorithm} {
+				if fields[key] != want {
+					t.Errorf("%s = %q, want %q", key, fields[key], want)
+				}
+			}
+
+			digest := func(parts ...string) string {
+				h := test.newHash()
+				h.Write([]byte(strings.Join(parts, ":")))
+				return hex.EncodeToString(h.Sum(nil))
+			}
+			ha1 := digest(username, realm, password)
+			if test.session {
+				ha1 = digest(ha1, nonce, fields["cnonce"])
+			}
+			ha2 := digest(method, uri)
+			want := digest(ha1, nonce, ha2)
+			if test.wantQop != "" {
+				if fields["nc"] != "00000001" || fields["cnonce"] == "" {
+					t.Errorf("nc = %q and cnonce = %q, want 00000001 and a cnonce", fields["nc"], fields["cnonce"])
+				}
+				want = digest(ha1, nonce, fields["nc"], fields["cnonce"], test.wantQop, ha2)
+			} else if _, ok := fields["cnonce"]; ok {
+				t.Errorf("cnonce sent without qop")
+			}
+			if fields["response"] != want {
+				t.Errorf("response = %s, want %s", fields["response"], want)
+			}
+		})
+	}
+}
+
+func TestDigestAuthorizationUnsupportedAlgorithm(t *testing.T) {
+	_, err := digestAuthorization(`Digest realm="r", nonce="n", algorithm=SHA-512-256`, "u", "p", "GET", "/")
+	if err == nil || !strings.Contains(err.Error(), "unsupported digest algorithm SHA-512-256") {
+		t.Errorf("digestAuthorization() error = %v, want an unsupported algorithm error", err)
+	}
+}
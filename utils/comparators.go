@@ -0,0 +1,247 @@
+package dash
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tidwall/gjson"
+)
+
+// comparatorAliases maps the operators accepted so far onto the named
+// comparators.
+var comparatorAliases = map[string]string{
+	"==": "eq", "=": "eq", "!=": "ne",
+	">": "gt", ">=": "gte", "<": "lt", "<=": "lte",
+	"=~": "matches",
+}
+
+// compare checks one extracted value against a validator. It returns whether
+// the check passed and a description of it, which explains the actual value
+// when it failed.
+func compare(validate Validate, actual gjson.Result) (bool, string) {
+	comparator := strings.ToLower(strings.TrimSpace(validate.Comparator))
+	if comparator == "" && validate.Expected == "" {
+		comparator = "exists"
+	} else if comparator == "" {
+		comparator = "eq"
+	}
+	if alias, ok := comparatorAliases[comparator]; ok {
+		comparator = alias
+	}
+	expected := validate.Expected
+	description := fmt.Sprintf("%s %s '%s'", validate.Extract, comparator, expected)
+	switch comparator {
+	case "exists", "not_exists", "is_null", "not_null":
+		description = fmt.Sprintf("%s %s", validate.Extract, comparator)
+	}
+	fail := func(format string, args ...interface{}) (bool, string) {
+		return false, description + ": " + fmt.Sprintf(format, args...)
+	}
+
+	switch comparator {
+	case "exists":
+		if !actual.Exists() {
+			return fail("nothing found")
+		}
+		return true, description
+	case "not_exists":
+		if actual.Exists() {
+			return fail("found '%s'", actual.String())
+		}
+		return true, description
+	}
+	if !actual.Exists() {
+		return fail("nothing found")
+	}
+
+	switch comparator {
+	case "eq", "ne":
+		equal := valuesEqual(actual, expected)
+		if equal != (comparator == "eq") {
+			return fail("got '%s'", actual.String())
+		}
+		return true, description
+	case "gt", "gte", "lt", "lte":
+		got, ok := toNumber(actual)
+		if !ok {
+			return fail("'%s' is not a number", actual.String())
+		}
+		want, err := strconv.ParseFloat(strings.TrimSpace(expected), 64)
+		if err != nil {
+			return fail("expected value '%s' is not a number", expected)
+		}
+		if !numericCheck(comparator, got, want) {
+			return fail("got %s", actual.String())
+		}
+		return true, description
+	case "contains", "not_contains":
+		found := containsValue(actual, expected)
+		if found != (comparator == "contains") {
+			return fail("got '%s'", actual.String())
+		}
+		return true, description
+	case "matches":
+		pattern, err := regexp.Compile(expected)
+		if err != nil {
+			return fail("invalid regex: %v", err)
+		}
+		if !pattern.MatchString(actual.String()) {
+			return fail("got '%s'", actual.String())
+		}
+		return true, description
+	case "in", "not_in":
+		found := false
+		for _, option := range expectedList(expected) {
+			if valuesEqual(actual, option) {
+				found = true
+				break
+			}
+		}
+		if found != (comparator == "in") {
+			return fail("got '%s'", actual.String())
+		}
+		return true, description
+	case "is_null", "not_null":
+		if (actual.Type == gjson.Null) != (comparator == "is_null") {
+			return fail("got '%s'", actual.Raw)
+		}
+		return true, description
+	case "type_is":
+		got := jsonType(actual)
+		want := strings.ToLower(strings.TrimSpace(expected))
+		if got != want && !(want == "integer" && got == "number" && actual.Num == float64(int64(actual.Num))) {
+			return fail("got %s", got)
+		}
+		return true, description
+	case "length_eq", "length_gt", "length_gte", "length_lt", "length_lte":
+		length, ok := valueLength(actual)
+		if !ok {
+			return fail("%s has no length", jsonType(actual))
+		}
+		want, err := strconv.Atoi(strings.TrimSpace(expected))
+		if err != nil {
+			return fail("expected length '%s' is not an integer", expected)
+		}
+		check := strings.TrimPrefix(comparator, "length_")
+		if check == "eq" {
+			if length != want {
+				return fail("length is %d", length)
+			}
+			return true, description
+		}
+		if !numericCheck(check, float64(length), float64(want)) {
+			return fail("length is %d", length)
+		}
+		return true, description
+	}
+	return fail("unknown comparator '%s'", validate.Comparator)
+}
+
+// valuesEqual compares numerically when both sides are numbers, so "1.0"
+// equals 1, and falls back to comparing the text.
+func valuesEqual(actual gjson.Result, expected string) bool {
+	if got, ok := toNumber(actual); ok {
+		if want, err := strconv.ParseFloat(strings.TrimSpace(expected), 64); err == nil {
+			return got == want
+		}
+	}
+	if actual.Type == gjson.Null {
+		return expected == "null" || expected == ""
+	}
+	return actual.String() == expected
+}
+
+func toNumber(value gjson.Result) (float64, bool) {
+	switch value.Type {
+	case gjson.Number:
+		return value.Num, true
+	case gjson.String:
+		number, err := strconv.ParseFloat(strings.TrimSpace(value.Str), 64)
+		return number, err == nil
+	}
+	return 0, false
+}
+
+func numericCheck(comparator string, got, want float64) bool {
+	switch comparator {
+	case "gt":
+		return got > want
+	case "gte":
+		return got >= want
+	case "lt":
+		return got < want
+	case "lte":
+		return got <= want
+	}
+	return false
+}
+
+// containsValue looks for an element of an array, a key of an object or a
+// substring of anything else.
+func containsValue(actual gjson.Result, expected string) bool {
+	switch {
+	case actual.IsArray():
+		for _, item := range actual.Array() {
+			if valuesEqual(item, expected) {
+				return true
+			}
+		}
+		return false
+	case actual.IsObject():
+		_, ok := actual.Map()[expected]
+		return ok
+	}
+	return strings.Contains(actual.String(), expected)
+}
+
+// expectedList reads the options of in/not_in, either a json array or a
+// comma separated list.
+func expectedList(expected string) []string {
+	var items []interface{}
+	if err := json.Unmarshal([]byte(expected), &items); err == nil {
+		options := make([]string, 0, len(items))
+		for _, item := range items {
+			options = append(options, fmt.Sprint(item))
+		}
+		return options
+	}
+	options := strings.Split(expected, ",")
+	for i := range options {
+		options[i] = strings.TrimSpace(options[i])
+	}
+	return options
+}
+
+func jsonType(value gjson.Result) string {
+	switch {
+	case value.IsArray():
+		return "array"
+	case value.IsObject():
+		return "object"
+	}
+	switch value.Type {
+	case gjson.String:
+		return "string"
+	case gjson.Number:
+		return "number"
+	case gjson.True, gjson.False:
+		return "boolean"
+	}
+	return "null"
+}
+
+func valueLength(value gjson.Result) (int, bool) {
+	switch {
+	case value.IsArray():
+		return len(value.Array()), true
+	case value.IsObject():
+		return len(value.Map()), true
+	case value.Type == gjson.String:
+		return utf8.RuneCountInString(value.Str), true
+	}
+	return 0, false
+}
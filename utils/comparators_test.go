@@ -0,0 +1,119 @@
+package dash
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestCompare(t *testing.T) {
+	body := `{
+		"id": 7,
+		"price": "12.50",
+		"name": "Jane Doe",
+		"email": "jane@example.com",
+		"active": true,
+		"deleted": null,
+		"tags": ["a", "b", 3],
+		"address": {"city": "Oslo", "zip": "0150"},
+		"emoji": "héllo"
+	}`
+	tests := []struct {
+		name       string
+		extract    string
+		comparator string
+		expected   string
+		passed     bool
+		reason     string
+	}{
+		{name: "default is eq", extract: "id", expected: "7", passed: true},
+		{name: "default without expected is exists", extract: "id", passed: true},
+		{name: "default exists on a missing path", extract: "missing", passed: false, reason: "nothing found"},
+		{name: "eq alias", extract: "id", comparator: "==", expected: "7", passed: true},
+		{name: "eq compares numbers numerically", extract: "id", comparator: "eq", expected: "7.0", passed: true},
+		{name: "eq on a numeric string", extract: "price", comparator: "eq", expected: "12.5", passed: true},
+		{name: "eq text", extract: "name", comparator: "eq", expected: "Jane Doe", passed: true},
+		{name: "eq failure explains the actual value", extract: "name", comparator: "eq", expected: "John", passed: false, reason: "got 'Jane Doe'"},
+		{name: "eq null", extract: "deleted", comparator: "eq", expected: "null", passed: true},
+		{name: "ne", extract: "id", comparator: "!=", expected: "8", passed: true},
+		{name: "ne failure", extract: "id", comparator: "ne", expected: "7", passed: false},
+		{name: "gt", extract: "id", comparator: ">", expected: "6", passed: true},
+		{name: "gte equal", extract: "id", comparator: ">=", expected: "7", passed: true},
+		{name: "lt failure", extract: "id", comparator: "<", expected: "7", passed: false, reason: "got 7"},
+		{name: "lte on a numeric string", extract: "price", comparator: "lte", expected: "12.5", passed: true},
+		{name: "gt on text", extract: "name", comparator: "gt", expected: "1", passed: false, reason: "is not a number"},
+		{name: "gt with a text expected value", extract: "id", comparator: "gt", expected: "one", passed: false, reason: "expected value 'one' is not a number"},
+		{name: "contains array element", extract: "tags", comparator: "contains", expected: "b", passed: true},
+		{name: "contains array number", extract: "tags", comparator: "contains", expected: "3", passed: true},
+		{name: "contains object key", extract: "address", comparator: "contains", expected: "city", passed: true},
+		{name: "contains substring", extract: "email", comparator: "contains", expected: "@example", passed: true},
+		{name: "not_contains", extract: "tags", comparator: "not_contains", expected: "z", passed: true},
+		{name: "not_contains failure", extract: "tags", comparator: "not_contains", expected: "a", passed: false},
+		{name: "matches", extract: "email", comparator: "matches", expected: `^[a-z]+@example\.com$`, passed: true},
+		{name: "matches alias", extract: "email", comparator: "=~", expected: `^jane@`, passed: true},
+		{name: "matches failure", extract: "email", comparator: "matches", expected: `^john`, passed: false},
+		{name: "matches invalid regex", extract: "email", comparator: "matches", expected: `(`, passed: false, reason: "invalid regex"},
+		{name: "in comma list", extract: "address.city", comparator: "in", expected: "Bergen, Oslo", passed: true},
+		{name: "in json array", extract: "id", comparator: "in", expected: "[5, 7]", passed: true},
+		{name: "in failure", extract: "id", comparator: "in", expected: "1,2", passed: false},
+		{name: "not_in", extract: "address.city", comparator: "not_in", expected: "Bergen,Trondheim", passed: true},
+		{name: "exists", extract: "address.zip", comparator: "exists", passed: true},
+		{name: "exists on null", extract: "deleted", comparator: "exists", passed: true},
+		{name: "not_exists", extract: "address.street", comparator: "not_exists", passed: true},
+		{name: "not_exists failure", extract: "id", comparator: "not_exists", passed: false, reason: "found '7'"},
+		{name: "is_null", extract: "deleted", comparator: "is_null", passed: true},
+		{name: "is_null failure", extract: "id", comparator: "is_null", passed: false},
+		{name: "not_null", extract: "id", comparator: "not_null", passed: true},
+		{name: "comparators on a missing path", extract: "missing", comparator: "gt", expected: "1", passed: false, reason: "nothing found"},
+		{name: "type_is string", extract: "name", comparator: "type_is", expected: "string", passed: true},
+		{name: "type_is integer", extract: "id", comparator: "type_is", expected: "integer", passed: true},
+		{name: "type_is number", extract: "id", comparator: "type_is", expected: "number", passed: true},
+		{name: "type_is boolean", extract: "active", comparator: "type_is", expected: "Boolean", passed: true},
+		{name: "type_is array", extract: "tags", comparator: "type_is", expected: "array", passed: true},
+		{name: "type_is object", extract: "address", comparator: "type_is", expected: "object", passed: true},
+		{name: "type_is null", extract: "deleted", comparator: "type_is", expected: "null", passed: true},
+		{name: "type_is failure", extract: "price", comparator: "type_is", expected: "number", passed: false, reason: "got string"},
+		{name: "length_eq array", extract: "tags", comparator: "length_eq", expected: "3", passed: true},
+		{name: "length_eq counts runes", extract: "emoji", comparator: "length_eq", expected: "5", passed: true},
+		{name: "length_gt object", extract: "address", comparator: "length_gt", expected: "1", passed: true},
+		{name: "length_lte failure", extract: "tags", comparator: "length_lte", expected: "2", passed: false, reason: "length is 3"},
+		{name: "length of a number", extract: "id", comparator: "length_eq", expected: "1", passed: false, reason: "number has no length"},
+		{name: "length with a text expected value", extract: "tags", comparator: "length_eq", expected: "three", passed: false, reason: "is not an integer"},
+		{name: "comparator case and spaces", extract: "id", comparator: " EQ ", expected: "7", passed: true},
+		{name: "unknown comparator", extract: "id", comparator: "near", expected: "7", passed: false, reason: "unknown comparator 'near'"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			validate := Validate{Extract: test.extract, Comparator: test.comparator, Expected: test.expected}
+			passed, description := compare(validate, gjson.Get(body, test.extract))
+			if passed != test.passed {
+				t.Fatalf("compare() = %v (%s), want %v", passed, description, test.passed)
+			}
+			if !strings.HasPrefix(description, test.extract+" ") {
+				t.Errorf("description %q does not start with the extract path", description)
+			}
+			if test.reason != "" && !strings.Contains(description, test.reason) {
+				t.Errorf("description %q does not contain %q", description, test.reason)
+			}
+		})
+	}
+}
+
+func TestExpectedList(t *testing.T) {
+	tests := []struct {
+		expected string
+		want     []string
+	}{
+		{expected: "a,b", want: []string{"a", "b"}},
+		{expected: " a , b ", want: []string{"a", "b"}},
+		{expected: `["a", 2, true]`, want: []string{"a", "2", "true"}},
+		{expected: "single", want: []string{"single"}},
+	}
+	for _, test := range tests {
+		got := expectedList(test.expected)
+		if strings.Join(got, "|") != strings.Join(test.want, "|") {
+			t.Errorf("expectedList(%q) = %q, want %q", test.expected, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package dash
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// contractSpec is the OpenAPI spec responses are checked against, nil when
+// the run has no -contract.
+var contractSpec *OpenAPISpec
+
+// LoadContract loads the OpenAPI 3 or Swagger 2 spec of the -contract flag.
+func LoadContract(path string) error {
+	spec, err := LoadOpenAPI(path)
+	if err != nil {
+		return err
+	}
+	contractSpec = spec
+	return nil
+}
+
+// checkContract checks a response against the operation of the contract that
+// documents it: the content type and the body schema count as validators, an
+// undocumented endpoint or status code is a warning.
+func checkContract(response *http.Response, body string, validateOutcome *ValidateOutcome) {
+	if contractSpec == nil || response.Request == nil {
+		return
+	}
+	request := response.Request
+	op, ok := contractSpec.FindOperation(request.Method, request.URL)
+	if !ok {
+		addWarning(validateOutcome, "contract: %s %s is not documented", request.Method, request.URL.Path)
+		return
+	}
+	where := op.Method + " " + op.Path
+	contents, ok := op.Response(response.StatusCode)
+	if !ok {
+		addWarning(validateOutcome, "contract: status %d is not documented for %s", response.StatusCode, where)
+		return
+	}
+	if len(contents) == 0 {
+		return
+	}
+	mediaType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	content, ok := matchContent(contents, mediaType)
+	if !ok {
+		documented := make([]string, len(contents))
+		for i, c := range contents {
+			documented[i] = c.MediaType
+		}
+		validateOutcome.Failed += 1
+		validateOutcome.Actual += fmt.Sprintf("Failed -- contract: content type '%s' of %s %d is not one of %s\n",
+			mediaType, where, response.StatusCode, strings.Join(documented, ", "))
+		return
+	}
+	validateOutcome.Passed += 1
+	validateOutcome.Actual += fmt.Sprintf("Passed -- contract: content type '%s' of %s %d\n", mediaType, where, response.StatusCode)
+	if content.Schema == nil || !isJSONMediaType(mediaType) {
+		return
+	}
+	source, err := json.Marshal(JSONSchema(content.Schema))
+	if err != nil {
+		validateOutcome.Failed += 1
+		validateOutcome.Actual += fmt.Sprintf("Failed -- contract schema of %s %d: %v\n", where, response.StatusCode, err)
+		return
+	}
+	schema, err := compileInlineSchema(string(source))
+	if err != nil {
+		validateOutcome.Failed += 1
+		validateOutcome.Actual += fmt.Sprintf("Failed -- contract schema of %s %d: %v\n", where, response.StatusCode, err)
+		return
+	}
+	violations, err := schemaViolations(schema, body)
+	if err != nil {
+		violations = []string{err.Error()}
+	}
+	if len(violations) == 0 {
+		validateOutcome.Passed += 1
+		validateOutcome.Actual += fmt.Sprintf("Passed -- contract schema of %s %d\n", where, response.StatusCode)
+		return
+	}
+	validateOutcome.Failed += 1
+	for _, violation := range violations {
+		validateOutcome.Actual += fmt.Sprintf("Failed -- contract schema of %s %d: %s\n", where, response.StatusCode, violation)
+	}
+}
+
+// matchContent finds the documented body of a media type, honouring the
+// */* and type/* wildcards of the spec.
+func matchContent(contents []OpenAPIContent, mediaType string) (OpenAPIContent, bool) {
+	var wildcard *OpenAPIContent
+	for i, content := range contents {
+		documented, _, err := mime.ParseMediaType(content.MediaType)
+		if err != nil {
+			documented = strings.ToLower(content.MediaType)
+		}
+		switch {
+		case documented == mediaType:
+			return content, true
+		case documented == "*/*", strings.HasSuffix(documented, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(documented, "*")):
+			if wildcard == nil {
+				wildcard = &contents[i]
+			}
+		}
+	}
+	if wildcard != nil {
+		return *wildcard, true
+	}
+	return OpenAPIContent{}, false
+}
+
+func addWarning(validateOutcome *ValidateOutcome, format string, args ...interface{}) {
+	warning := fmt.Sprintf(format, args...)
+	validateOutcome.Warnings = append(validateOutcome.Warnings, warning)
+	validateOutcome.Actual += fmt.Sprintln("Warning --", warning)
+}
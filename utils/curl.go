@@ -0,0 +1,181 @@
+package dash
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// defaultMask hides the auth credentials of a curl command when
+// MaskedFields has no mask of its own for them.
+const defaultMask = "****"
+
+// secretHeaders are masked even when they come from the scenario headers
+// rather than from its auth.
+var secretHeaders = []string{"Authorization", "Proxy-Authorization"}
+
+// ToCurl returns a curl command that repeats the request of a resolved
+// scenario: the url with its params, the headers merged from the service and
+// the config, the auth and the final body. The auth credentials are always
+// masked, MaskedFields decides for the other headers.
+func ToCurl(scenario Scenario) string {
+	return curlCommand(scenario, false)
+}
+
+// CurlCommand resolves a scenario against a config loaded without its init
+// steps and returns its curl command. The credentials of the auth are left as
+// {{token}} style placeholders, like the aliases of the init steps.
+func CurlCommand(scenario Scenario, config Config) string {
+	isolate(&scenario)
+	config = withExampleData(withRunVariables(config), scenario)
+	getService(&scenario, config)
+	bodyConfigs(&scenario, config)
+	urlConfigs(&scenario, config)
+	return curlCommand(scenario, true)
+}
+
+func curlCommand(scenario Scenario, placeholders bool) string {
+	method := strings.ToUpper(scenario.Method)
+	if method == "" {
+		method = "GET"
+	}
+	query := url.Values{}
+	for k, v := range scenario.Params {
+		query.Set(k, v)
+	}
+	headers := map[string]string{}
+	for k, v := range scenario.Headers {
+		headers[k] = v
+	}
+	if len(headers) == 0 {
+		headers["Content-Type"] = "application/json"
+	}
+	// in a report the credentials are resolved, they are masked whatever
+	// MaskedFields says; dash curl prints placeholders instead
+	masked := map[string]bool{}
+	if !placeholders {
+		for _, name := range secretHeaders {
+			masked[strings.ToLower(name)] = true
+		}
+	}
+
+	var options []string
+	// literal is the query value kept unescaped in the url, so that the
+	// placeholder or the mask stays readable
+	literal := ""
+	auth := scenario.Auth
+	switch strings.ToLower(auth.Type) {
+	case "basic", "digest":
+		credentials := "{{username}}:{{password}}"
+		if !placeholders {
+			credentials = maskOr(scenario.MaskedFields, "Authorization", defaultMask)
+		}
+		if strings.ToLower(auth.Type) == "digest" {
+			options = append(options, "--digest")
+		}
+		options = append(options, "-u "+shellQuote(credentials))
+		for k := range headers {
+			if strings.EqualFold(k, "Authorization") {
+				delete(headers, k)
+			}
+		}
+	case "bearer", "oauth2":
+		setHeader(headers, "Authorization", "Bearer {{token}}")
+		if !placeholders {
+			headers["Authorization"] = "Bearer " + maskOr(scenario.MaskedFields, "Authorization", defaultMask)
+			delete(masked, "authorization")
+		}
+	case "apikey":
+		name := auth.Key
+		if strings.ToLower(auth.In) == "query" {
+			if name == "" {
+				name = "api_key"
+			}
+			literal = "{{api_key}}"
+			if !placeholders {
+				literal = maskOr(scenario.MaskedFields, name, defaultMask)
+			}
+			query.Set(name, literal)
+			break
+		}
+		if name == "" {
+			name = "X-API-Key"
+		}
+		setHeader(headers, name, "{{api_key}}")
+		masked[strings.ToLower(name)] = !placeholders
+	}
+	requestURL := scenario.Url
+	if len(query) > 0 {
+		if parsed, err := url.Parse(requestURL); err == nil {
+			parsed.RawQuery = query.Encode()
+			if literal != "" {
+				parsed.RawQuery = strings.ReplaceAll(parsed.RawQuery, url.QueryEscape(literal), literal)
+			}
+			requestURL = parsed.String()
+		}
+	}
+
+	parts := []string{"curl"}
+	body := scenario.FinalBody
+	if body == "" && scenario.Body != "" {
+		body = scenario.Body
+		if scenario.Type != "soap" && scenario.Tag != "urlencoded" {
+			body = strings.ReplaceAll(body, "\\", ``)
+		}
+	}
+	if method != "GET" || body != "" {
+		parts = append(parts, "-X "+method)
+	}
+	parts = append(parts, shellQuote(requestURL))
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := headers[name]
+		if masked[strings.ToLower(name)] {
+			value = maskOr(scenario.MaskedFields, name, defaultMask)
+		} else if mask, ok := maskFor(scenario.MaskedFields, name); ok {
+			value = mask
+		}
+		parts = append(parts, "-H "+shellQuote(name+": "+value))
+	}
+	parts = append(parts, options...)
+	if body != "" {
+		parts = append(parts, "--data-raw "+shellQuote(body))
+	}
+	return strings.Join(parts, " \\\n  ")
+}
+
+// setHeader replaces a header whatever the case of its existing name, the
+// way the auth set on the request overrides the scenario headers.
+func setHeader(headers map[string]string, name, value string) {
+	for k := range headers {
+		if strings.EqualFold(k, name) {
+			delete(headers, k)
+		}
+	}
+	headers[name] = value
+}
+
+func maskOr(masked map[string]string, name, fallback string) string {
+	if mask, ok := maskFor(masked, name); ok {
+		return mask
+	}
+	return fallback
+}
+
+func maskFor(masked map[string]string, name string) (string, bool) {
+	for k, v := range masked {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// shellQuote single quotes a value for sh and bash.
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
@@ -0,0 +1,215 @@
+package dash
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Filter selects the scenarios of a run. Service, Severity, Priority and
+// Labels are expressions such as "critical,high", "!low" or
+// "smoke && !(slow || flaky)": a comma or || is an or, && an and and ! a not.
+// Name is a regular expression, or one prefixed with ! to exclude matches.
+// A scenario is run when it matches every filter that is set.
+type Filter struct {
+	Service  string
+	Severity string
+	Priority string
+	Labels   string
+	Name     string
+}
+
+// filterExpr is a parsed filter expression, true when it matches a
+// scenario's values.
+type filterExpr func(values []string) bool
+
+func (filter Filter) isSet() bool {
+	return filter.Service != "" || filter.Severity != "" || filter.Priority != "" || filter.Labels != "" || filter.Name != ""
+}
+
+// FilterScenarios keeps the scenarios selected by the filter, together with
+// the scenarios they depend on so that depends_on chains still run.
+func FilterScenarios(scenarios []Scenario, filter Filter) ([]Scenario, error) {
+	if !filter.isSet() {
+		return scenarios, nil
+	}
+	match, err := filter.compile()
+	if err != nil {
+		return nil, err
+	}
+	byName := indexByName(scenarios)
+	keep := make([]bool, len(scenarios))
+	var pending []int
+	for i, scenario := range scenarios {
+		if match(scenario) {
+			keep[i] = true
+			pending = append(pending, i)
+		}
+	}
+	for len(pending) > 0 {
+		i := pending[0]
+		pending = pending[1:]
+		for _, name := range scenarios[i].DependsOn {
+			for _, t := range byName[name] {
+				if !keep[t] {
+					keep[t] = true
+					pending = append(pending, t)
+				}
+			}
+		}
+	}
+	var selected []Scenario
+	for i, scenario := range scenarios {
+		if keep[i] {
+			selected = append(selected, scenario)
+		}
+	}
+	return selected, nil
+}
+
+func (filter Filter) compile() (func(Scenario) bool, error) {
+	fields := []struct {
+		flag  string
+		expr  string
+		value func(Scenario) []string
+	}{
+		{"service", filter.Service, func(s Scenario) []string { return []string{s.Service} }},
+		{"severity", filter.Severity, func(s Scenario) []string { return []string{s.Severity} }},
+		{"priority", filter.Priority, func(s Scenario) []string { return []string{s.Priority} }},
+		{"labels", filter.Labels, func(s Scenario) []string { return s.Labels }},
+	}
+	var checks []func(Scenario) bool
+	for _, field := range fields {
+		if strings.TrimSpace(field.expr) == "" {
+			continue
+		}
+		expr, err := parseFilter(field.expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -%s filter '%s': %v", field.flag, field.expr, err)
+		}
+		value := field.value
+		checks = append(checks, func(s Scenario) bool { return expr(value(s)) })
+	}
+	if filter.Name != "" {
+		pattern, exclude := filter.Name, false
+		if strings.HasPrefix(pattern, "!") {
+			pattern, exclude = pattern[1:], true
+		}
+		name, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -name filter '%s': %v", filter.Name, err)
+		}
+		checks = append(checks, func(s Scenario) bool { return name.MatchString(s.Scenario) != exclude })
+	}
+	return func(scenario Scenario) bool {
+		for _, check := range checks {
+			if !check(scenario) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// filterParser is a recursive descent parser for
+//
+//	or    = and { ("||" | ",") and }
+//	and   = unary { "&&" unary }
+//	unary = "!" unary | "(" or ")" | word
+type filterParser struct {
+	tokens []string
+	pos    int
+}
+
+var filterTokens = regexp.MustCompile(`\|\||&&|[!(),]|[^\s!(),|&]+|\S`)
+
+func parseFilter(expr string) (filterExpr, error) {
+	parser := &filterParser{tokens: filterTokens.FindAllString(expr, -1)}
+	parsed, err := parser.or()
+	if err != nil {
+		return nil, err
+	}
+	if parser.pos < len(parser.tokens) {
+		return nil, fmt.Errorf("unexpected '%s'", parser.tokens[parser.pos])
+	}
+	return parsed, nil
+}
+
+func (parser *filterParser) peek() string {
+	if parser.pos < len(parser.tokens) {
+		return parser.tokens[parser.pos]
+	}
+	return ""
+}
+
+func (parser *filterParser) or() (filterExpr, error) {
+	left, err := parser.and()
+	if err != nil {
+		return nil, err
+	}
+	for parser.peek() == "||" || parser.peek() == "," {
+		parser.pos++
+		right, err := parser.and()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(values []string) bool { return l(values) || right(values) }
+	}
+	return left, nil
+}
+
+func (parser *filterParser) and() (filterExpr, error) {
+	left, err := parser.unary()
+	if err != nil {
+		return nil, err
+	}
+	for parser.peek() == "&&" {
+		parser.pos++
+		right, err := parser.unary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(values []string) bool { return l(values) && right(values) }
+	}
+	return left, nil
+}
+
+func (parser *filterParser) unary() (filterExpr, error) {
+	token := parser.peek()
+	parser.pos++
+	switch token {
+	case "":
+		return nil, fmt.Errorf("unexpected end of expression")
+	case "!":
+		operand, err := parser.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(values []string) bool { return !operand(values) }, nil
+	case "(":
+		inner, err := parser.or()
+		if err != nil {
+			return nil, err
+		}
+		if parser.peek() != ")" {
+			return nil, fmt.Errorf("missing ')'")
+		}
+		parser.pos++
+		return inner, nil
+	case ")", ",", "||", "&&":
+		return nil, fmt.Errorf("unexpected '%s'", token)
+	}
+	if strings.ContainsAny(token, "|&") {
+		return nil, fmt.Errorf("unexpected '%s'", token)
+	}
+	return func(values []string) bool {
+		for _, value := range values {
+			if strings.EqualFold(value, token) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
@@ -0,0 +1,127 @@
+package dash
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestParseFilter lists, for each expression, label sets it must and must
+// not select.
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		expr    string
+		match   [][]string
+		noMatch [][]string
+	}{
+		{expr: "smoke", match: [][]string{{"smoke"}, {"Smoke"}, {"slow", "smoke"}}, noMatch: [][]string{{"regression"}, nil, {"smoke-test"}}},
+		{expr: "critical, high", match: [][]string{{"high"}, {"critical"}}, noMatch: [][]string{{"low"}}},
+		{expr: "critical || high", match: [][]string{{"critical"}}, noMatch: [][]string{{"medium"}}},
+		{expr: "!low", match: [][]string{{"high"}, nil}, noMatch: [][]string{{"low"}, {"high", "low"}}},
+		{expr: "!!low", match: [][]string{{"low"}}, noMatch: [][]string{nil}},
+		{expr: "smoke && !slow", match: [][]string{{"smoke"}}, noMatch: [][]string{{"smoke", "slow"}, {"slow"}}},
+		{expr: "regression && !(slow || flaky)", match: [][]string{{"regression"}}, noMatch: [][]string{{"regression", "flaky"}, {"regression", "slow"}}},
+		// && binds tighter than || and the comma
+		{expr: "a || b && c", match: [][]string{{"a"}, {"b", "c"}}, noMatch: [][]string{{"b"}, {"c"}}},
+		{expr: "a, b && c", match: [][]string{{"a"}, {"b", "c"}}, noMatch: [][]string{{"b"}}},
+		{expr: "(a || b) && c", match: [][]string{{"a", "c"}, {"b", "c"}}, noMatch: [][]string{{"a"}, {"c"}}},
+		{expr: "((a))", match: [][]string{{"a"}}, noMatch: [][]string{{"b"}}},
+		{expr: "  a&&b  ", match: [][]string{{"a", "b"}}, noMatch: [][]string{{"a"}}},
+		{expr: "user-api && v2.1", match: [][]string{{"user-api", "v2.1"}}, noMatch: [][]string{{"user-api"}}},
+	}
+	for _, test := range tests {
+		expr, err := parseFilter(test.expr)
+		if err != nil {
+			t.Errorf("parseFilter(%q) failed: %v", test.expr, err)
+			continue
+		}
+		for _, values := range test.match {
+			if !expr(values) {
+				t.Errorf("%q does not select %q", test.expr, values)
+			}
+		}
+		for _, values := range test.noMatch {
+			if expr(values) {
+				t.Errorf("%q selects %q", test.expr, values)
+			}
+		}
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		err  string
+	}{
+		{expr: "", err: "unexpected end of expression"},
+		{expr: "smoke &&", err: "unexpected end of expression"},
+		{expr: "!", err: "unexpected end of expression"},
+		{expr: "(smoke", err: "missing ')'"},
+		{expr: "smoke)", err: "unexpected ')'"},
+		{expr: "smoke slow", err: "unexpected 'slow'"},
+		{expr: ",smoke", err: "unexpected ','"},
+		{expr: "&& smoke", err: "unexpected '&&'"},
+		{expr: "smoke & slow", err: "unexpected '&'"},
+		{expr: "smoke | slow", err: "unexpected '|'"},
+	}
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			_, err := parseFilter(test.expr)
+			if err == nil {
+				t.Fatalf("parseFilter(%q) succeeded, want an error", test.expr)
+			}
+			if !strings.Contains(err.Error(), test.err) {
+				t.Errorf("parseFilter(%q) error = %q, want %q", test.expr, err, test.err)
+			}
+		})
+	}
+}
+
+func TestFilterScenarios(t *testing.T) {
+	scenarios := []Scenario{
+		{Scenario: "Login", Service: "auth", Severity: "critical", Labels: []string{"smoke"}},
+		{Scenario: "List users", Service: "users", Severity: "high", Labels: []string{"smoke", "regression"}},
+		{Scenario: "Create user", Service: "users", Severity: "high", Labels: []string{"regression", "slow"}, DependsOn: []string{"Login"}},
+		{Scenario: "Legacy export", Service: "legacy", Severity: "low", Priority: "P3", Labels: []string{"regression", "flaky"}},
+	}
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+		err    string
+	}{
+		{name: "no filter", filter: Filter{}, want: []string{"Login", "List users", "Create user", "Legacy export"}},
+		{name: "service", filter: Filter{Service: "users"}, want: []string{"Login", "List users", "Create user"}},
+		{name: "excluded service", filter: Filter{Service: "!legacy"}, want: []string{"Login", "List users", "Create user"}},
+		{name: "severity list", filter: Filter{Severity: "critical,low"}, want: []string{"Login", "Legacy export"}},
+		{name: "priority", filter: Filter{Priority: "p3"}, want: []string{"Legacy export"}},
+		{name: "labels expression", filter: Filter{Labels: "regression && !(slow || flaky)"}, want: []string{"List users"}},
+		{name: "filters are and-ed", filter: Filter{Service: "users", Labels: "smoke"}, want: []string{"List users"}},
+		{name: "name regex", filter: Filter{Name: "^L"}, want: []string{"Login", "List users", "Legacy export"}},
+		{name: "excluded name", filter: Filter{Name: "!user"}, want: []string{"Login", "Legacy export"}},
+		{name: "dependencies are kept", filter: Filter{Labels: "slow"}, want: []string{"Login", "Create user"}},
+		{name: "nothing matches", filter: Filter{Severity: "blocker"}, want: nil},
+		{name: "invalid expression", filter: Filter{Labels: "smoke &&"}, err: "invalid -labels filter"},
+		{name: "invalid regex", filter: Filter{Name: "("}, err: "invalid -name filter"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			selected, err := FilterScenarios(scenarios, test.filter)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("FilterScenarios() error = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FilterScenarios() failed: %v", err)
+			}
+			var names []string
+			for _, scenario := range selected {
+				names = append(names, scenario.Scenario)
+			}
+			if strings.Join(names, "|") != strings.Join(test.want, "|") {
+				t.Errorf("FilterScenarios() = %q, want %q", names, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,617 @@
+package dash
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// The har types follow the HAR 1.2 spec, http://www.softwareishard.com/blog/har-12-spec/.
+
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Pages   []harPage  `json:"pages"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harPage struct {
+	StartedDateTime string         `json:"startedDateTime"`
+	ID              string         `json:"id"`
+	Title           string         `json:"title"`
+	PageTimings     harPageTimings `json:"pageTimings"`
+	started         time.Time
+}
+
+type harPageTimings struct {
+	OnContentLoad float64 `json:"onContentLoad"`
+	OnLoad        float64 `json:"onLoad"`
+}
+
+type harEntry struct {
+	Pageref         string      `json:"pageref,omitempty"`
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	Connection      string      `json:"connection,omitempty"`
+	Error           string      `json:"_error,omitempty"`
+	started         time.Time
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harCookie    `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harCookie    `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harCookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Path     string `json:"path,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	Expires  string `json:"expires,omitempty"`
+	HTTPOnly bool   `json:"httpOnly,omitempty"`
+	Secure   bool   `json:"secure,omitempty"`
+}
+
+type harPostData struct {
+	MimeType string         `json:"mimeType"`
+	Params   []harNameValue `json:"params,omitempty"`
+	Text     string         `json:"text"`
+}
+
+type harContent struct {
+	Size        int    `json:"size"`
+	Compression int    `json:"compression,omitempty"`
+	MimeType    string `json:"mimeType"`
+	Text        string `json:"text,omitempty"`
+	Encoding    string `json:"encoding,omitempty"`
+}
+
+// harTimings are in milliseconds, -1 when the phase did not happen, e.g. no
+// dns lookup or connect on a reused connection.
+type harTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// harRecorder collects the exchanges of the run while -o har is on. Every
+// request sent by a scenario, retries, polls, auth challenges, redirects and
+// hooks included, becomes an entry of the scenario's page; init functions and
+// teardown steps have a page each.
+type harRecorder struct {
+	lock    sync.Mutex
+	enabled bool
+	pages   map[string]*harPage
+	entries []harEntry
+}
+
+var harRecording = &harRecorder{pages: map[string]*harPage{}}
+
+type harScenarioKey struct{}
+
+// harScenario is what the transport needs to know about the scenario that
+// sent a request.
+type harScenario struct {
+	id     string
+	title  string
+	masked map[string]string
+}
+
+// RecordHAR turns the recorder on when -o asks for a har report. It is called
+// before the init functions so that their requests are recorded too.
+func RecordHAR(reportOut string) {
+	for _, format := range reportFormats(reportOut) {
+		if format == "har" {
+			harRecording.lock.Lock()
+			harRecording.enabled = true
+			harRecording.lock.Unlock()
+		}
+	}
+}
+
+func (recorder *harRecorder) isEnabled() bool {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	return recorder.enabled
+}
+
+// withHARScenario tags the requests of a scenario, its hooks included, for
+// the recorder.
+func withHARScenario(ctx context.Context, scenario *Scenario) context.Context {
+	return withHARPage(ctx, scenario.ID, scenario.Scenario, scenario.MaskedFields)
+}
+
+// withHARPage tags requests that belong to no scenario, such as the init
+// functions, for the recorder.
+func withHARPage(ctx context.Context, id, title string, masked map[string]string) context.Context {
+	if !harRecording.isEnabled() {
+		return ctx
+	}
+	return context.WithValue(ctx, harScenarioKey{}, harScenario{id: id, title: title, masked: masked})
+}
+
+func (recorder *harRecorder) add(owner harScenario, entry harEntry) {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	page, ok := recorder.pages[owner.id]
+	if !ok || entry.started.Before(page.started) {
+		if !ok {
+			page = &harPage{ID: owner.id, Title: owner.title}
+			recorder.pages[owner.id] = page
+		}
+		page.started, page.StartedDateTime = entry.started, entry.StartedDateTime
+	}
+	end := entry.started.Add(time.Duration(entry.Time * float64(time.Millisecond)))
+	if onLoad := float64(end.Sub(page.started)) / float64(time.Millisecond); onLoad > page.PageTimings.OnLoad {
+		page.PageTimings.OnLoad = onLoad
+	}
+	entry.Pageref = owner.id
+	recorder.entries = append(recorder.entries, entry)
+}
+
+// harTransport records the exchanges of tagged requests and passes the
+// others straight to the wrapped transport.
+type harTransport struct {
+	transport http.RoundTripper
+}
+
+func (t *harTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	owner, ok := request.Context().Value(harScenarioKey{}).(harScenario)
+	if !ok {
+		return t.transport.RoundTrip(request)
+	}
+	var requestBody []byte
+	if request.Body != nil && request.Body != http.NoBody {
+		if request.GetBody != nil {
+			if body, err := request.GetBody(); err == nil {
+				requestBody, _ = ioutil.ReadAll(body)
+				body.Close()
+			}
+		} else {
+			requestBody, _ = ioutil.ReadAll(request.Body)
+			request.Body.Close()
+			request.Body = ioutil.NopCloser(bytes.NewReader(requestBody))
+		}
+	}
+
+	timer := &harTimer{start: time.Now()}
+	response, err := t.transport.RoundTrip(request.WithContext(httptrace.WithClientTrace(request.Context(), timer.trace())))
+	entry := harEntry{started: timer.start, StartedDateTime: timer.start.Format(time.RFC3339Nano)}
+	entry.Request = harRequestOf(request, requestBody, owner.masked)
+	if err != nil {
+		entry.Error = err.Error()
+		entry.Response = harResponse{Cookies: []harCookie{}, Headers: []harNameValue{}, Content: harContent{MimeType: "x-unknown"}, HeadersSize: -1, BodySize: -1}
+		entry.Timings = timer.timings(time.Now())
+		entry.Time = entry.Timings.total()
+		harRecording.add(owner, entry)
+		return nil, err
+	}
+	// the body is copied as the client reads it, so that recording does not
+	// change when the response time of the scenario stops
+	response.Body = &harBody{body: response.Body, done: func(body []byte, readErr error) {
+		if readErr != nil {
+			entry.Error = readErr.Error()
+		}
+		entry.Timings = timer.timings(time.Now())
+		entry.Time = entry.Timings.total()
+		entry.Response = harResponseOf(response, body, owner.masked)
+		entry.ServerIPAddress, entry.Connection = timer.remote()
+		harRecording.add(owner, entry)
+	}}
+	return response, nil
+}
+
+// harBody copies a response body while it is read and hands it over to done
+// once it was read to the end or closed.
+type harBody struct {
+	body   io.ReadCloser
+	buffer bytes.Buffer
+	done   func(body []byte, err error)
+	once   sync.Once
+}
+
+func (body *harBody) Read(p []byte) (int, error) {
+	n, err := body.body.Read(p)
+	body.buffer.Write(p[:n])
+	if err != nil {
+		body.finish(err)
+	}
+	return n, err
+}
+
+func (body *harBody) Close() error {
+	err := body.body.Close()
+	body.finish(nil)
+	return err
+}
+
+func (body *harBody) finish(err error) {
+	body.once.Do(func() {
+		if err == io.EOF {
+			err = nil
+		}
+		body.done(body.buffer.Bytes(), err)
+	})
+}
+
+// harTimer follows a request through the phases of httptrace. The dial
+// callbacks may run on other goroutines.
+type harTimer struct {
+	lock                             sync.Mutex
+	start, dnsStart, dnsDone         time.Time
+	connectStart, connectDone        time.Time
+	tlsStart, tlsDone                time.Time
+	gotConn, wroteRequest, firstByte time.Time
+	remoteAddr, localAddr            string
+}
+
+func (timer *harTimer) mark(at *time.Time) {
+	timer.lock.Lock()
+	defer timer.lock.Unlock()
+	if at.IsZero() {
+		*at = time.Now()
+	}
+}
+
+func (timer *harTimer) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { timer.mark(&timer.dnsStart) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { timer.mark(&timer.dnsDone) },
+		ConnectStart:      func(string, string) { timer.mark(&timer.connectStart) },
+		ConnectDone:       func(string, string, error) { timer.mark(&timer.connectDone) },
+		TLSHandshakeStart: func() { timer.mark(&timer.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { timer.mark(&timer.tlsDone) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			timer.mark(&timer.gotConn)
+			timer.lock.Lock()
+			defer timer.lock.Unlock()
+			timer.remoteAddr, timer.localAddr = info.Conn.RemoteAddr().String(), info.Conn.LocalAddr().String()
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { timer.mark(&timer.wroteRequest) },
+		GotFirstResponseByte: func() { timer.mark(&timer.firstByte) },
+	}
+}
+
+func (timer *harTimer) timings(end time.Time) harTimings {
+	timer.lock.Lock()
+	defer timer.lock.Unlock()
+	span := func(from, to time.Time) float64 {
+		if from.IsZero() || to.IsZero() {
+			return -1
+		}
+		return float64(to.Sub(from)) / float64(time.Millisecond)
+	}
+	timings := harTimings{
+		DNS:     span(timer.dnsStart, timer.dnsDone),
+		Connect: span(timer.connectStart, timer.tlsDone),
+		SSL:     span(timer.tlsStart, timer.tlsDone),
+		Send:    span(timer.gotConn, timer.wroteRequest),
+		Wait:    span(timer.wroteRequest, timer.firstByte),
+		Receive: span(timer.firstByte, end),
+	}
+	if timings.Connect < 0 {
+		timings.Connect = span(timer.connectStart, timer.connectDone)
+	}
+	blocked := span(timer.start, timer.gotConn)
+	if blocked < 0 {
+		blocked = span(timer.start, end)
+	}
+	for _, phase := range []float64{timings.DNS, timings.Connect} {
+		if phase > 0 {
+			blocked -= phase
+		}
+	}
+	if blocked < 0 {
+		blocked = 0
+	}
+	timings.Blocked = blocked
+	for _, phase := range []*float64{&timings.Send, &timings.Wait, &timings.Receive} {
+		if *phase < 0 {
+			*phase = 0
+		}
+	}
+	return timings
+}
+
+func (timer *harTimer) remote() (string, string) {
+	timer.lock.Lock()
+	defer timer.lock.Unlock()
+	host := timer.remoteAddr
+	if i := strings.LastIndex(host, ":"); i >= 0 {
+		host = strings.Trim(host[:i], "[]")
+	}
+	connection := timer.localAddr
+	if i := strings.LastIndex(connection, ":"); i >= 0 {
+		connection = connection[i+1:]
+	}
+	return host, connection
+}
+
+// total is the time of the entry: the sum of the phases that happened, ssl
+// being part of connect.
+func (timings harTimings) total() float64 {
+	var total float64
+	for _, phase := range []float64{timings.Blocked, timings.DNS, timings.Connect, timings.Send, timings.Wait, timings.Receive} {
+		if phase > 0 {
+			total += phase
+		}
+	}
+	return total
+}
+
+func harRequestOf(request *http.Request, body []byte, masked map[string]string) harRequest {
+	out := harRequest{
+		Method:      request.Method,
+		HTTPVersion: request.Proto,
+		Cookies:     []harCookie{},
+		Headers:     harHeaders(request.Header, masked),
+		QueryString: []harNameValue{},
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+	u := *request.URL
+	query := u.Query()
+	for _, name := range sortedKeys(query) {
+		for _, value := range query[name] {
+			if mask, ok := harMask(masked, name, harSecretFields); ok {
+				value = mask
+			}
+			out.QueryString = append(out.QueryString, harNameValue{Name: name, Value: value})
+		}
+		if mask, ok := harMask(masked, name, harSecretFields); ok {
+			query[name] = []string{mask}
+		}
+	}
+	u.RawQuery = query.Encode()
+	if len(out.QueryString) == 0 {
+		u.RawQuery = request.URL.RawQuery
+	}
+	out.URL = u.String()
+	if out.HTTPVersion == "" {
+		out.HTTPVersion = "HTTP/1.1"
+	}
+	for _, cookie := range request.Cookies() {
+		value := cookie.Value
+		if mask, ok := maskFor(masked, "Cookie"); ok {
+			value = mask
+		}
+		out.Cookies = append(out.Cookies, harCookie{Name: cookie.Name, Value: value})
+	}
+	if len(body) > 0 {
+		mimeType := request.Header.Get("Content-Type")
+		out.PostData = &harPostData{MimeType: mimeType, Text: maskBody(mimeType, body, masked)}
+		if mediaType, _, _ := mime.ParseMediaType(mimeType); mediaType == "application/x-www-form-urlencoded" {
+			if form, err := url.ParseQuery(out.PostData.Text); err == nil {
+				for _, name := range sortedKeys(form) {
+					for _, value := range form[name] {
+						out.PostData.Params = append(out.PostData.Params, harNameValue{Name: name, Value: value})
+					}
+				}
+			}
+		}
+	}
+	return out
+}
+
+// harSecretFields are the oauth2 grant and token fields, masked like the
+// secretHeaders even when MaskedFields does not name them.
+var harSecretFields = []string{"client_secret", "password", "refresh_token", "access_token"}
+
+// harMask is the mask MaskedFields gives a header, parameter or field, or
+// defaultMask when it is one of the secrets.
+func harMask(masked map[string]string, name string, secrets []string) (string, bool) {
+	if mask, ok := maskFor(masked, name); ok {
+		return mask, true
+	}
+	for _, secret := range secrets {
+		if strings.EqualFold(secret, name) {
+			return defaultMask, true
+		}
+	}
+	return "", false
+}
+
+// maskBody masks the json fields and form parameters named in MaskedFields
+// or harSecretFields, whatever their depth in a json body. Other bodies are
+// kept as they are.
+func maskBody(mimeType string, body []byte, masked map[string]string) string {
+	mediaType, _, _ := mime.ParseMediaType(mimeType)
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return string(body)
+		}
+		changed := false
+		for name := range form {
+			if mask, ok := harMask(masked, name, harSecretFields); ok {
+				form[name] = []string{mask}
+				changed = true
+			}
+		}
+		if changed {
+			return form.Encode()
+		}
+	case strings.HasSuffix(mediaType, "json"):
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		var document interface{}
+		if decoder.Decode(&document) != nil || !maskJSON(document, masked) {
+			return string(body)
+		}
+		if out, err := json.Marshal(document); err == nil {
+			return string(out)
+		}
+	}
+	return string(body)
+}
+
+func maskJSON(node interface{}, masked map[string]string) bool {
+	changed := false
+	switch value := node.(type) {
+	case map[string]interface{}:
+		for k, v := range value {
+			if mask, ok := harMask(masked, k, harSecretFields); ok {
+				value[k] = mask
+				changed = true
+			} else if maskJSON(v, masked) {
+				changed = true
+			}
+		}
+	case []interface{}:
+		for _, v := range value {
+			if maskJSON(v, masked) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+func harResponseOf(response *http.Response, body []byte, masked map[string]string) harResponse {
+	out := harResponse{
+		Status:      response.StatusCode,
+		StatusText:  strings.TrimSpace(strings.TrimPrefix(response.Status, fmt.Sprint(response.StatusCode))),
+		HTTPVersion: response.Proto,
+		Cookies:     []harCookie{},
+		Headers:     harHeaders(response.Header, masked),
+		RedirectURL: response.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+	for _, cookie := range response.Cookies() {
+		value := cookie.Value
+		if mask, ok := maskFor(masked, "Set-Cookie"); ok {
+			value = mask
+		}
+		entry := harCookie{Name: cookie.Name, Value: value, Path: cookie.Path, Domain: cookie.Domain, HTTPOnly: cookie.HttpOnly, Secure: cookie.Secure}
+		if !cookie.Expires.IsZero() {
+			entry.Expires = cookie.Expires.Format(time.RFC3339)
+		}
+		out.Cookies = append(out.Cookies, entry)
+	}
+	content := body
+	if strings.EqualFold(response.Header.Get("Content-Encoding"), "gzip") {
+		if reader, err := gzip.NewReader(bytes.NewReader(body)); err == nil {
+			if decoded, err := ioutil.ReadAll(reader); err == nil {
+				content = decoded
+			}
+		}
+	}
+	out.Content = harContent{Size: len(content), Compression: len(content) - len(body), MimeType: response.Header.Get("Content-Type")}
+	if out.Content.MimeType == "" {
+		out.Content.MimeType = "x-unknown"
+	}
+	if utf8.Valid(content) {
+		out.Content.Text = maskBody(out.Content.MimeType, content, masked)
+	} else {
+		out.Content.Text = base64.StdEncoding.EncodeToString(content)
+		out.Content.Encoding = "base64"
+	}
+	return out
+}
+
+func harHeaders(header http.Header, masked map[string]string) []harNameValue {
+	out := []harNameValue{}
+	for _, name := range sortedKeys(header) {
+		for _, value := range header[name] {
+			if mask, ok := harMask(masked, name, secretHeaders); ok {
+				value = mask
+			}
+			out = append(out, harNameValue{Name: name, Value: value})
+		}
+	}
+	return out
+}
+
+func sortedKeys(values map[string][]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// saveToHAR writes the recorded exchanges, in the order they started.
+func saveToHAR(sessionID string) {
+	harRecording.lock.Lock()
+	entries := append([]harEntry{}, harRecording.entries...)
+	pages := make([]harPage, 0, len(harRecording.pages))
+	for _, page := range harRecording.pages {
+		pages = append(pages, *page)
+	}
+	harRecording.lock.Unlock()
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].started.Before(entries[j].started) })
+	sort.SliceStable(pages, func(i, j int) bool { return pages[i].started.Before(pages[j].started) })
+
+	out, err := json.MarshalIndent(map[string]harLog{"log": {
+		Version: "1.2",
+		Creator: harCreator{Name: "dash", Version: "1.0.0"},
+		Pages:   pages,
+		Entries: entries,
+	}}, "", " ")
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	err = ioutil.WriteFile(fmt.Sprintf("Report-%s.har", strings.Replace(sessionID, ":", "-", -1)), out, 0644)
+	if err != nil {
+		log.Error(err)
+	}
+}
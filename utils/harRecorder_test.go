@@ -0,0 +1,92 @@
+package dash
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHARMasksSecrets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"access_token": "at-secret", "token_type": "bearer", "expires_in": 3600, "refresh_token": "rt-secret"}`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id": 7}`)
+	}))
+	defer server.Close()
+
+	recording := harRecording
+	harRecording = &harRecorder{enabled: true, pages: map[string]*harPage{}}
+	defer func() { harRecording = recording }()
+
+	client := newClient(&http.Transport{})
+	ctx := withHARPage(context.Background(), "users", "Users", nil)
+	for _, auth := range []Auth{
+		{Type: "bearer", Token: "bearer-secret"},
+		{Type: "oauth2", OAuth2: OAuth2{
+			GrantType: "password", TokenURL: server.URL + "/token", ClientID: "dash", ClientSecret: "cs-secret",
+			ClientAuth: "body", Username: "neo", Password: "pw-secret",
+		}},
+	} {
+		request, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/users/7", nil)
+		if err := applyAuth(auth, request, client); err != nil {
+			t.Fatalf("applyAuth(%s) failed: %v", auth.Type, err)
+		}
+		response, err := client.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(response.Body)
+		response.Body.Close()
+	}
+
+	if len(harRecording.entries) != 3 {
+		t.Fatalf("recorded %d entries, want the two requests and the token request", len(harRecording.entries))
+	}
+	out, err := json.Marshal(harRecording.entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"bearer-secret", "at-secret", "rt-secret", "cs-secret", "pw-secret"} {
+		if strings.Contains(string(out), secret) {
+			t.Errorf("the HAR holds %s: %s", secret, out)
+		}
+	}
+	for _, kept := range []string{`"value":"****"`, "client_id=dash", "username=neo", `\"expires_in\":3600`} {
+		if !strings.Contains(string(out), kept) {
+			t.Errorf("the HAR lacks %s: %s", kept, out)
+		}
+	}
+}
+
+func TestMaskBody(t *testing.T) {
+	masked := map[string]string{"card": "XXXX", "password": "hidden"}
+	tests := []struct {
+		name     string
+		mimeType string
+		body     string
+		want     string
+	}{
+		{name: "token response", mimeType: "application/json", body: `{"access_token":"a","expires_in":60,"refresh_token":"r"}`, want: `{"access_token":"****","expires_in":60,"refresh_token":"****"}`},
+		{name: "nested json fields", mimeType: "application/vnd.api+json", body: `{"user":[{"card":"4111","name":"neo"}]}`, want: `{"user":[{"card":"XXXX","name":"neo"}]}`},
+		{name: "maskedfields mask wins over the default", mimeType: "application/x-www-form-urlencoded", body: "grant_type=password&password=p&client_secret=s", want: "client_secret=%2A%2A%2A%2A&grant_type=password&password=hidden"},
+		{name: "nothing to mask", mimeType: "application/json", body: `{"name": "neo"}`, want: `{"name": "neo"}`},
+		{name: "other bodies are kept", mimeType: "text/plain", body: "password=p", want: "password=p"},
+		{name: "broken json is kept", mimeType: "application/json", body: `{"password":`, want: `{"password":`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := maskBody(test.mimeType, []byte(test.body), masked); got != test.want {
+				t.Errorf("maskBody() = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package dash
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// headerValue returns a response header as a string result, multiple values
+// joined by commas. A missing header is a result that does not exist, so the
+// exists/not_exists comparators work as for body paths.
+func headerValue(response *http.Response, name string) gjson.Result {
+	values, ok := response.Header[http.CanonicalHeaderKey(name)]
+	if !ok {
+		return gjson.Result{}
+	}
+	return stringResult(strings.Join(values, ", "))
+}
+
+// cookieValue returns the value of a Set-Cookie cookie, or one of its
+// attributes: secure, httponly, samesite, path, domain, expires or max_age.
+func cookieValue(response *http.Response, name string, attribute string) (gjson.Result, error) {
+	var cookie *http.Cookie
+	for _, c := range response.Cookies() {
+		if c.Name == name {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		return gjson.Result{}, nil
+	}
+	switch strings.ToLower(attribute) {
+	case "", "value":
+		return stringResult(cookie.Value), nil
+	case "secure":
+		return boolResult(cookie.Secure), nil
+	case "httponly":
+		return boolResult(cookie.HttpOnly), nil
+	case "samesite":
+		switch cookie.SameSite {
+		case http.SameSiteLaxMode:
+			return stringResult("Lax"), nil
+		case http.SameSiteStrictMode:
+			return stringResult("Strict"), nil
+		case http.SameSiteNoneMode:
+			return stringResult("None"), nil
+		}
+		return gjson.Result{}, nil
+	case "path":
+		return optionalResult(cookie.Path), nil
+	case "domain":
+		return optionalResult(cookie.Domain), nil
+	case "expires":
+		if cookie.RawExpires == "" {
+			return gjson.Result{}, nil
+		}
+		return stringResult(cookie.RawExpires), nil
+	case "max_age", "maxage":
+		if cookie.MaxAge == 0 {
+			return gjson.Result{}, nil
+		}
+		return gjson.Result{Type: gjson.Number, Num: float64(cookie.MaxAge), Raw: strconv.Itoa(cookie.MaxAge)}, nil
+	}
+	return gjson.Result{}, fmt.Errorf("unknown cookie attribute '%s'", attribute)
+}
+
+// validateResponseMeta runs a header or cookie validator.
+func validateResponseMeta(response *http.Response, validate Validate) (bool, string) {
+	kind := strings.ToLower(validate.Type)
+	if kind == "header" {
+		described := validate
+		described.Extract = "header " + validate.Extract
+		return compare(described, headerValue(response, validate.Extract))
+	}
+	described := validate
+	described.Extract = strings.TrimSpace("cookie " + validate.Extract + " " + strings.ToLower(validate.Attribute))
+	value, err := cookieValue(response, validate.Extract, validate.Attribute)
+	if err != nil {
+		return false, fmt.Sprintf("%s: %v", described.Extract, err)
+	}
+	return compare(described, value)
+}
+
+func stringResult(value string) gjson.Result {
+	return gjson.Result{Type: gjson.String, Str: value, Raw: strconv.Quote(value)}
+}
+
+func optionalResult(value string) gjson.Result {
+	if value == "" {
+		return gjson.Result{}
+	}
+	return stringResult(value)
+}
+
+func boolResult(value bool) gjson.Result {
+	if value {
+		return gjson.Result{Type: gjson.True, Raw: "true"}
+	}
+	return gjson.Result{Type: gjson.False, Raw: "false"}
+}
@@ -0,0 +1,65 @@
+package dash
+
+import (
+	"context"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// runHooks runs the before or after steps of a scenario and records their
+// results on it. Values extracted by the steps are added to the returned
+// config so the scenario can use them. Every step runs even if an earlier one
+// failed; the first error is returned. Before steps stop with the run, after
+// steps still run once it was cancelled since they clean up.
+func runHooks(scenario *Scenario, phase string, steps []InitFunc, config Config) (Config, error) {
+	var firstErr error
+	values := map[string]string{}
+	parent := runContext
+	if phase != "before" {
+		parent = context.Background()
+	}
+	parent = withHARScenario(parent, scenario)
+	for i, step := range steps {
+		if step.Name == "" {
+			step.Name = fmt.Sprintf("%s %d", phase, i+1)
+		}
+		extracted, report, err := runStep(parent, step, withData(config, values))
+		report.Phase = phase
+		report.ID = scenario.ID
+		report.Severity = scenario.Severity
+		report.Priority = scenario.Priority
+		scenario.HookReports = append(scenario.HookReports, report)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s step '%s' failed: %v", phase, step.Name, err)
+			}
+			continue
+		}
+		for alias, value := range extracted {
+			values[alias] = value
+		}
+	}
+	return withData(config, values), firstErr
+}
+
+// RunTeardown runs the teardown steps of the config. It is called once all
+// scenarios are done, whatever their outcome, and keeps going when a step
+// fails so that as much test data as possible is cleaned up. It does not
+// stop with the run, only with the timeout of the config.
+func RunTeardown(config Config) []ReportTemplate {
+	var reports []ReportTemplate
+	config = withRunVariables(config)
+	for i, step := range config.Teardown {
+		if step.Name == "" {
+			step.Name = fmt.Sprintf("teardown %d", i+1)
+		}
+		_, report, err := runStep(withHARPage(context.Background(), "teardown", "Teardown", config.MaskedFields), step, config)
+		report.Phase = "teardown"
+		reports = append(reports, report)
+		if err != nil {
+			log.Errorf("teardown step '%s' failed: %v", step.Name, err)
+		}
+	}
+	return reports
+}
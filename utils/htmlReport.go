@@ -0,0 +1,61 @@
+package dash
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	//go:embed assets/report.html
+	htmlReportPage string
+	//go:embed assets/report.css
+	htmlReportCSS string
+	//go:embed assets/report.js
+	htmlReportJS       string
+	htmlReportTemplate = template.Must(template.New("report").Parse(htmlReportPage))
+)
+
+type htmlReportData struct {
+	Metadata Metadata
+	RunID    string
+	RunAt    string
+	Reports  []ReportTemplate
+	CSS      template.CSS
+	JS       template.JS
+}
+
+// saveToHTML writes a single, offline html report: styles, scripts and the
+// results are all inlined in the page.
+func saveToHTML(reports []ReportTemplate, sessionID string, metadata Metadata) {
+	runAt := time.Now().Format("2006-01-02 15:04:05")
+	if len(reports) > 0 && reports[0].ExecutionTime != "" {
+		runAt = reports[0].ExecutionTime
+	}
+	if reports == nil {
+		reports = []ReportTemplate{}
+	}
+	var page bytes.Buffer
+	err := htmlReportTemplate.Execute(&page, htmlReportData{
+		Metadata: metadata,
+		RunID:    sessionID,
+		RunAt:    runAt,
+		Reports:  reports,
+		CSS:      template.CSS(htmlReportCSS),
+		JS:       template.JS(htmlReportJS),
+	})
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	err = ioutil.WriteFile(fmt.Sprintf("Report-%s.html", strings.Replace(sessionID, ":", "-", -1)), page.Bytes(), 0644)
+	if err != nil {
+		log.Error(err)
+	}
+}
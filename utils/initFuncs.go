@@ -0,0 +1,206 @@
+package dash
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+// RunInitFuncs runs the initfuncs steps in order. Every value a step extracts
+// is stored in config.Data under its alias, so later steps and scenarios can
+// use it as {{alias}}. The run stops at the first failing step. The reports
+// of the steps that ran are returned even then, so that they can be written.
+func RunInitFuncs(config Config) (Config, []ReportTemplate, error) {
+	var reports []ReportTemplate
+	if config.Data == nil {
+		config.Data = map[string]string{}
+	}
+	for i, step := range config.InitFuncs {
+		if step.Name == "" {
+			step.Name = fmt.Sprintf("initfunc %d", i+1)
+		}
+		values, report, err := runStep(withHARPage(runContext, "setup", "Init functions", config.MaskedFields), step, config)
+		report.Phase = "setup"
+		reports = append(reports, report)
+		if err != nil {
+			return config, reports, fmt.Errorf("init function '%s' failed: %v", step.Name, err)
+		}
+		for alias, value := range values {
+			config.Data[alias] = value
+		}
+	}
+	return config, reports, nil
+}
+
+// runStep executes one step and extracts its values. The report is filled in
+// even when the step fails. The step is bound to parent and to the timeout of
+// the config.
+func runStep(parent context.Context, step InitFunc, config Config) (map[string]string, ReportTemplate, error) {
+	step = step.resolve(config)
+	report := ReportTemplate{
+		Scenario: step.Name,
+		Service:  step.Action,
+		Method:   step.Method,
+		Url:      step.URL,
+	}
+	ctx, cancel, err := stepContext(parent, config)
+	if err != nil {
+		report.FinalTestStatus = "error"
+		report.ErrorDescription = err.Error()
+		return nil, report, err
+	}
+	defer cancel()
+	start := time.Now()
+	output, err := step.execute(ctx, &report)
+	report.ResponseTime = time.Since(start).Seconds()
+	report.ResponseBody = strings.Replace(output, "\"", "'", -1)
+	if err != nil {
+		report.FinalTestStatus = "error"
+		report.ErrorDescription = err.Error()
+		return nil, report, err
+	}
+	values, err := step.extract(output)
+	if err != nil {
+		report.FinalTestStatus = "failed"
+		report.FailedCount = 1
+		report.ValidationDescription = fmt.Sprintln("Failed --", err)
+		return nil, report, err
+	}
+	names := make([]string, 0, len(values))
+	for alias := range values {
+		names = append(names, alias)
+	}
+	sort.Strings(names)
+	for _, alias := range names {
+		report.PassCount += 1
+		report.ValidationDescription += fmt.Sprintln("Extracted --", alias)
+	}
+	report.FinalTestStatus = "passed"
+	return values, report, nil
+}
+
+func (step InitFunc) resolve(config Config) InitFunc {
+	for _, field := range []*string{&step.URL, &step.Body, &step.Command, &step.Path} {
+		found := regex.FindAllString(*field, -1)
+		if len(found) != 0 {
+			*field = recurse(found, *field, config)
+		}
+	}
+	headers := make(map[string]string, len(step.Headers))
+	for k, v := range step.Headers {
+		found := regex.FindAllString(v, -1)
+		if len(found) != 0 {
+			v = recurse(found, v, config)
+		}
+		headers[k] = v
+	}
+	step.Headers = headers
+	step.Action = strings.ToLower(step.Action)
+	if step.Action == "" || step.Action == "generate_access_token" {
+		step.Action = "http"
+	}
+	step.Method = strings.ToUpper(step.Method)
+	if step.Action == "http" && step.Method == "" {
+		step.Method = http.MethodGet
+	}
+	return step
+}
+
+func (step InitFunc) execute(ctx context.Context, report *ReportTemplate) (string, error) {
+	switch step.Action {
+	case "http":
+		request, err := http.NewRequestWithContext(ctx, step.Method, step.URL, strings.NewReader(step.Body))
+		if err != nil {
+			return "", err
+		}
+		for k, v := range step.Headers {
+			request.Header[k] = []string{v}
+		}
+		if request.Header.Get("Content-Type") == "" && step.Body != "" {
+			request.Header.Set("Content-Type", "application/json")
+		}
+		response, err := defaultClient.Do(request)
+		if err != nil {
+			return "", err
+		}
+		defer response.Body.Close()
+		out, err := ioutil.ReadAll(response.Body)
+		report.ResponseCode = response.StatusCode
+		if err != nil {
+			return "", err
+		}
+		if response.StatusCode/100 != 2 {
+			return string(out), fmt.Errorf("%s %s returned status %d", step.Method, step.URL, response.StatusCode)
+		}
+		return string(out), nil
+	case "shell":
+		if step.Command == "" {
+			return "", fmt.Errorf("shell step needs a command")
+		}
+		var command *exec.Cmd
+		if runtime.GOOS == "windows" {
+			command = exec.CommandContext(ctx, "cmd", "/C", step.Command)
+		} else {
+			command = exec.CommandContext(ctx, "sh", "-c", step.Command)
+		}
+		var stdout, stderr strings.Builder
+		command.Stdout, command.Stderr = &stdout, &stderr
+		if err := command.Start(); err != nil {
+			return "", err
+		}
+		done := make(chan error, 1)
+		go func() { done <- command.Wait() }()
+		select {
+		case err := <-done:
+			if err != nil {
+				return stdout.String(), fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+			}
+			return stdout.String(), nil
+		case <-ctx.Done():
+			// the shell is killed, but a command it started may still hold
+			// the output open, so the step does not wait for it
+			return "", fmt.Errorf("shell step stopped: %v", ctx.Err())
+		}
+	case "file":
+		if step.Path == "" {
+			return "", fmt.Errorf("file step needs a path")
+		}
+		out, err := ioutil.ReadFile(step.Path)
+		return string(out), err
+	}
+	return "", fmt.Errorf("unsupported action %s, use http, shell or file", step.Action)
+}
+
+// extract reads the step's values out of its output. Extract maps aliases to
+// gjson paths; the legacy alias/getvalue pair adds one more. An empty path
+// takes the whole trimmed output.
+func (step InitFunc) extract(output string) (map[string]string, error) {
+	paths := map[string]string{}
+	for alias, path := range step.Extract {
+		paths[alias] = path
+	}
+	if step.Alias != "" {
+		paths[step.Alias] = step.GetValue
+	}
+	values := map[string]string{}
+	for alias, path := range paths {
+		if path == "" {
+			values[alias] = strings.TrimSpace(output)
+			continue
+		}
+		value := gjson.Get(output, path)
+		if !value.Exists() {
+			return nil, fmt.Errorf("nothing found at '%s' for %s", path, alias)
+		}
+		values[alias] = value.String()
+	}
+	return values, nil
+}
@@ -0,0 +1,57 @@
+package dash
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// TestRunStepTimeout checks that a hanging step gives up after the config
+// timeout, and that the step is not stopped when no timeout is set.
+func TestRunStepTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name    string
+		parent  context.Context
+		timeout string
+		step    InitFunc
+		failed  bool
+	}{
+		{name: "slow command", parent: context.Background(), timeout: "100ms", step: InitFunc{Action: "shell", Command: "sleep 2"}, failed: true},
+		{name: "slow request", parent: context.Background(), timeout: "0.1", step: InitFunc{Action: "http", Method: "GET", URL: server.URL}, failed: true},
+		{name: "fast command", parent: context.Background(), timeout: "2s", step: InitFunc{Action: "shell", Command: "echo ok"}},
+		{name: "no timeout", parent: context.Background(), step: InitFunc{Action: "shell", Command: "sleep 0.2"}},
+		{name: "cancelled parent", parent: cancelled, timeout: "2s", step: InitFunc{Action: "shell", Command: "sleep 2"}, failed: true},
+		{name: "invalid timeout", parent: context.Background(), timeout: "soon", step: InitFunc{Action: "shell", Command: "echo ok"}, failed: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			start := time.Now()
+			_, report, err := runStep(test.parent, test.step, Config{Timeout: test.timeout})
+			if (err != nil) != test.failed {
+				t.Fatalf("runStep() error = %v, want failed %v", err, test.failed)
+			}
+			if test.failed && report.FinalTestStatus != "error" {
+				t.Errorf("report status = %q, want error", report.FinalTestStatus)
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("runStep() took %v", elapsed)
+			}
+		})
+	}
+}
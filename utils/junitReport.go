@@ -0,0 +1,150 @@
+package dash
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	Cases     []junitTestCase `xml:"testcase"`
+	seconds   float64
+}
+
+type junitTestCase struct {
+	Name      string         `xml:"name,attr"`
+	Classname string         `xml:"classname,attr"`
+	Time      string         `xml:"time,attr"`
+	Failures  []junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage  `xml:"error,omitempty"`
+	Skipped   *junitMessage  `xml:"skipped,omitempty"`
+	SystemOut *junitOutput   `xml:"system-out,omitempty"`
+}
+
+type junitOutput struct {
+	Text string `xml:",cdata"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",cdata"`
+}
+
+// junitReport maps the reports onto JUnit: one testsuite per service (hooks
+// get a suite per phase), one testcase per scenario, one failure per failed
+// validation and an error for scenarios that never got a response.
+func junitReport(reports []ReportTemplate, sessionID string) junitTestSuites {
+	out := junitTestSuites{Name: "dash " + sessionID}
+	index := map[string]int{}
+	var total float64
+	for _, report := range reports {
+		name := report.Service
+		if report.Phase != "" && report.Phase != "test" {
+			name = report.Phase
+		}
+		if name == "" {
+			name = "default"
+		}
+		i, ok := index[name]
+		if !ok {
+			i = len(out.Suites)
+			index[name] = i
+			out.Suites = append(out.Suites, junitTestSuite{Name: name, Timestamp: strings.Replace(report.ExecutionTime, " ", "T", 1)})
+		}
+		suite := &out.Suites[i]
+		testCase := junitTestCase{
+			Name:      report.Scenario,
+			Classname: junitClassname(report, name),
+			Time:      fmt.Sprintf("%.3f", report.ResponseTime),
+			SystemOut: &junitOutput{Text: fmt.Sprintf("id: %s\n%s %s\nresponse code: %d\nresponse body: %s\n", report.ID, report.Method, report.Url, report.ResponseCode, report.ResponseBody)},
+		}
+		if report.Curl != "" {
+			testCase.SystemOut.Text += "curl:\n" + report.Curl + "\n"
+		}
+		if report.Warnings != "" {
+			testCase.SystemOut.Text += "warnings:\n" + report.Warnings + "\n"
+		}
+		switch report.FinalTestStatus {
+		case "error":
+			message := report.ErrorDescription
+			if report.ErrorReason != "" {
+				message = report.ErrorReason + ": " + report.ErrorDescription
+			}
+			testCase.Error = &junitMessage{Message: message, Type: "error", Text: report.ErrorDescription}
+			suite.Errors++
+		case "skipped", "cancelled":
+			message := strings.TrimPrefix(strings.TrimPrefix(report.ValidationDescription, "Skipped --"), "Cancelled --")
+			testCase.Skipped = &junitMessage{Message: strings.TrimSpace(message)}
+			suite.Skipped++
+		case "failed":
+			for _, line := range strings.Split(report.ValidationDescription, "\n") {
+				if strings.HasPrefix(line, "Failed") {
+					testCase.Failures = append(testCase.Failures, junitMessage{Message: strings.TrimSpace(line), Type: "validation", Text: report.ValidationDescription})
+				}
+			}
+			if len(testCase.Failures) == 0 {
+				testCase.Failures = append(testCase.Failures, junitMessage{Message: "failed", Type: "validation", Text: report.ValidationDescription})
+			}
+			suite.Failures++
+		}
+		suite.Tests++
+		suite.seconds += report.ResponseTime
+		suite.Cases = append(suite.Cases, testCase)
+	}
+	for i := range out.Suites {
+		suite := &out.Suites[i]
+		suite.Time = fmt.Sprintf("%.3f", suite.seconds)
+		out.Tests += suite.Tests
+		out.Failures += suite.Failures
+		out.Errors += suite.Errors
+		out.Skipped += suite.Skipped
+		total += suite.seconds
+	}
+	out.Time = fmt.Sprintf("%.3f", total)
+	return out
+}
+
+func junitClassname(report ReportTemplate, suite string) string {
+	var parts []string
+	for _, part := range []string{report.Project, suite} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+func saveToJUnit(reports []ReportTemplate, sessionID string) {
+	file, err := xml.MarshalIndent(junitReport(reports, sessionID), "", " ")
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	file = append([]byte(xml.Header), file...)
+	err = ioutil.WriteFile(fmt.Sprintf("junit-%s.xml", strings.Replace(sessionID, ":", "-", -1)), file, 0644)
+	if err != nil {
+		log.Error(err)
+	}
+}
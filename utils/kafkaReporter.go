@@ -0,0 +1,114 @@
+package dash
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	log "github.com/sirupsen/logrus"
+)
+
+// kafkaReporter streams results to the configured topic while the run is in
+// progress. Messages are keyed by run id so a run stays on one partition, and
+// are written asynchronously in batches; delivery errors are collected by the
+// completion callback and summarised when the reporter is closed.
+type kafkaReporter struct {
+	writer        *kafka.Writer
+	runID         string
+	withScenarios bool
+
+	lock      sync.Mutex
+	delivered int
+	failed    int
+	lastErr   error
+}
+
+func newKafkaReporter(config Config, runID string) *kafkaReporter {
+	if !config.Metadata.Stream {
+		return nil
+	}
+	if appConfig.Brokers == "" || appConfig.Topic == "" {
+		log.Warn("metadata.stream is enabled but brokers or topic are missing from the app config, results will not be streamed.")
+		return nil
+	}
+	var brokers []string
+	for _, broker := range strings.Split(appConfig.Brokers, ",") {
+		if broker = strings.TrimSpace(broker); broker != "" {
+			brokers = append(brokers, broker)
+		}
+	}
+	reporter := &kafkaReporter{runID: runID, withScenarios: config.Metadata.StreamScenarios}
+	reporter.writer = &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        appConfig.Topic,
+		Balancer:     &kafka.Hash{},
+		BatchSize:    100,
+		BatchTimeout: 500 * time.Millisecond,
+		MaxAttempts:  3,
+		WriteTimeout: 10 * time.Second,
+		RequiredAcks: kafka.RequireOne,
+		Async:        true,
+		Completion:   reporter.completion,
+	}
+	log.Infof("Streaming results to kafka topic %s on %s", appConfig.Topic, strings.Join(brokers, ","))
+	return reporter
+}
+
+func (reporter *kafkaReporter) completion(messages []kafka.Message, err error) {
+	reporter.lock.Lock()
+	defer reporter.lock.Unlock()
+	if err != nil {
+		reporter.failed += len(messages)
+		reporter.lastErr = err
+		log.Errorf("Failed to deliver %d messages to kafka: %v", len(messages), err)
+		return
+	}
+	reporter.delivered += len(messages)
+}
+
+// publish queues a report, and the scenario it came from when
+// metadata.streamscenarios is set. It is safe to call on a nil reporter.
+func (reporter *kafkaReporter) publish(report ReportTemplate, scenario *Scenario) {
+	if reporter == nil {
+		return
+	}
+	messages := []kafka.Message{reporter.message("report", report)}
+	if scenario != nil && reporter.withScenarios {
+		messages = append(messages, reporter.message("scenario", scenario))
+	}
+	if err := reporter.writer.WriteMessages(context.Background(), messages...); err != nil {
+		reporter.completion(messages, err)
+	}
+}
+
+func (reporter *kafkaReporter) message(kind string, value interface{}) kafka.Message {
+	out, err := json.Marshal(value)
+	if err != nil {
+		log.Error(err)
+	}
+	return kafka.Message{
+		Key:     []byte(reporter.runID),
+		Value:   out,
+		Headers: []kafka.Header{{Key: "type", Value: []byte(kind)}},
+	}
+}
+
+// close flushes the pending batches and logs the delivery summary.
+func (reporter *kafkaReporter) close() {
+	if reporter == nil {
+		return
+	}
+	if err := reporter.writer.Close(); err != nil {
+		log.Errorf("Error closing the kafka writer: %v", err)
+	}
+	reporter.lock.Lock()
+	defer reporter.lock.Unlock()
+	if reporter.failed > 0 {
+		log.Errorf("Streamed %d messages to kafka, %d failed. Last error: %v", reporter.delivered, reporter.failed, reporter.lastErr)
+		return
+	}
+	log.Infof("Streamed %d messages to kafka topic %s", reporter.delivered, appConfig.Topic)
+}
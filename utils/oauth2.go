@@ -0,0 +1,202 @@
+package dash
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OAuth2 struct
+// GrantType is client_credentials, password or refresh_token. ClientAuth
+// selects how the client credentials are sent: header (basic auth, default)
+// or body.
+type OAuth2 struct {
+	GrantType    string
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	ClientAuth   string
+	Username     string
+	Password     string
+	Scope        string
+	Audience     string
+	RefreshToken string
+}
+
+// tokenProvider caches the token of one oauth2 configuration and renews it
+// when it expires or when a request is rejected with a 401.
+type tokenProvider struct {
+	lock         sync.Mutex
+	config       OAuth2
+	accessToken  string
+	refreshToken string
+	expiry       time.Time
+}
+
+type tokenResponse struct {
+	AccessToken      string      `json:"access_token"`
+	TokenType        string      `json:"token_type"`
+	ExpiresIn        json.Number `json:"expires_in"`
+	RefreshToken     string      `json:"refresh_token"`
+	Error            string      `json:"error"`
+	ErrorDescription string      `json:"error_description"`
+}
+
+// expiryMargin renews tokens slightly before the server would reject them.
+const expiryMargin = 30 * time.Second
+
+var (
+	tokenProvidersLock sync.Mutex
+	tokenProviders     = map[OAuth2]*tokenProvider{}
+)
+
+func providerFor(config OAuth2) *tokenProvider {
+	tokenProvidersLock.Lock()
+	defer tokenProvidersLock.Unlock()
+	provider, ok := tokenProviders[config]
+	if !ok {
+		provider = &tokenProvider{config: config, refreshToken: config.RefreshToken}
+		tokenProviders[config] = provider
+	}
+	return provider
+}
+
+// OAuth2Token returns a valid access token for the configuration, requesting
+// one from the token endpoint when none is cached. It is bound to the run.
+func OAuth2Token(config OAuth2) (string, error) {
+	return providerFor(config).token(runContext, defaultClient)
+}
+
+// token returns the cached token or requests one with the client and the
+// context of the scenario that needs it, so that the request honours its
+// timeout, proxy and the cancellation of the run.
+func (provider *tokenProvider) token(ctx context.Context, client *http.Client) (string, error) {
+	provider.lock.Lock()
+	defer provider.lock.Unlock()
+	if provider.accessToken != "" && (provider.expiry.IsZero() || time.Now().Before(provider.expiry)) {
+		return provider.accessToken, nil
+	}
+	grant := strings.ToLower(provider.config.GrantType)
+	if grant == "" {
+		grant = "client_credentials"
+	}
+	if provider.refreshToken != "" {
+		err := provider.request(ctx, client, "refresh_token")
+		if err == nil || grant == "refresh_token" {
+			return provider.accessToken, err
+		}
+	}
+	err := provider.request(ctx, client, grant)
+	return provider.accessToken, err
+}
+
+// invalidate drops the cached token if it is still the one that was rejected,
+// so concurrent scenarios hitting the same 401 only renew it once.
+func (provider *tokenProvider) invalidate(token string) {
+	provider.lock.Lock()
+	defer provider.lock.Unlock()
+	if provider.accessToken == token {
+		provider.accessToken = ""
+	}
+}
+
+func (provider *tokenProvider) request(ctx context.Context, client *http.Client, grant string) error {
+	config := provider.config
+	if config.TokenURL == "" {
+		return fmt.Errorf("oauth2 tokenurl is required")
+	}
+	clientID, err := resolveAuthValue(config.ClientID)
+	if err != nil {
+		return err
+	}
+	clientSecret, err := resolveAuthValue(config.ClientSecret)
+	if err != nil {
+		return err
+	}
+	form := url.Values{}
+	form.Set("grant_type", grant)
+	switch grant {
+	case "client_credentials":
+	case "password":
+		username, err := resolveAuthValue(config.Username)
+		if err != nil {
+			return err
+		}
+		password, err := resolveAuthValue(config.Password)
+		if err != nil {
+			return err
+		}
+		form.Set("username", username)
+		form.Set("password", password)
+	case "refresh_token":
+		if provider.refreshToken == "" {
+			return fmt.Errorf("oauth2 refresh_token grant needs a refreshtoken")
+		}
+		refreshToken, err := resolveAuthValue(provider.refreshToken)
+		if err != nil {
+			return err
+		}
+		form.Set("refresh_token", refreshToken)
+	default:
+		return fmt.Errorf("unsupported oauth2 grant type %s", grant)
+	}
+	if config.Scope != "" {
+		form.Set("scope", config.Scope)
+	}
+	if config.Audience != "" {
+		form.Set("audience", config.Audience)
+	}
+	if strings.ToLower(config.ClientAuth) == "body" {
+		form.Set("client_id", clientID)
+		form.Set("client_secret", clientSecret)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, config.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	if strings.ToLower(config.ClientAuth) != "body" && clientID != "" {
+		request.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(clientSecret))
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("oauth2 %s token request failed: %v", grant, err)
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return fmt.Errorf("oauth2 %s token response: %v", grant, err)
+	}
+	var token tokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		return fmt.Errorf("oauth2 %s token response (status %d) is not json: %s", grant, response.StatusCode, body)
+	}
+	if response.StatusCode/100 != 2 || token.Error != "" {
+		return fmt.Errorf("oauth2 %s token request rejected (status %d): %s %s", grant, response.StatusCode, token.Error, token.ErrorDescription)
+	}
+	if token.AccessToken == "" {
+		return fmt.Errorf("oauth2 %s token response has no access_token", grant)
+	}
+	provider.accessToken = token.AccessToken
+	provider.expiry = time.Time{}
+	if seconds, err := token.ExpiresIn.Int64(); err == nil && seconds > 0 {
+		lifetime := time.Duration(seconds) * time.Second
+		margin := expiryMargin
+		if lifetime/2 < margin {
+			margin = lifetime / 2
+		}
+		provider.expiry = time.Now().Add(lifetime - margin)
+	}
+	if token.RefreshToken != "" {
+		provider.refreshToken = token.RefreshToken
+	}
+	return nil
+}
@@ -0,0 +1,566 @@
+package dash
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// OpenAPISpec is an OpenAPI 3 or Swagger 2 document with its local $refs
+// resolved, reduced to what dash generates scenarios from and checks
+// responses against.
+type OpenAPISpec struct {
+	Title           string
+	Servers         []string
+	SecuritySchemes map[string]map[string]interface{}
+	Security        []string
+	Operations      []*OpenAPIOperation
+}
+
+// OpenAPIOperation is one method of one path.
+type OpenAPIOperation struct {
+	Method      string
+	Path        string
+	OperationID string
+	Summary     string
+	Tags        []string
+	Parameters  []OpenAPIParameter
+	Body        *OpenAPIContent
+	Responses   map[string][]OpenAPIContent
+	pattern     *regexp.Regexp
+}
+
+// OpenAPIParameter is a path, query or header parameter.
+type OpenAPIParameter struct {
+	Name     string
+	In       string
+	Required bool
+	Schema   map[string]interface{}
+	Example  interface{}
+}
+
+// OpenAPIContent is a request or response body of one media type. A
+// response without a body has a single content with no media type.
+type OpenAPIContent struct {
+	MediaType string
+	Schema    map[string]interface{}
+	Example   interface{}
+}
+
+// LoadOpenAPI reads a spec file in yaml or json.
+func LoadOpenAPI(path string) (*OpenAPISpec, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var document interface{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("reading %s: %v", path, err)
+	}
+	root, ok := normalizeYAML(document).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s is not an OpenAPI document", path)
+	}
+	if root, err = resolveRefs(root); err != nil {
+		return nil, fmt.Errorf("reading %s: %v", path, err)
+	}
+	spec := &OpenAPISpec{Title: stringField(mapField(root, "info"), "title")}
+	swagger := root["swagger"] != nil
+	if !swagger && root["openapi"] == nil {
+		return nil, fmt.Errorf("%s has neither an openapi nor a swagger version", path)
+	}
+	if swagger {
+		spec.Servers = swaggerServers(root)
+		spec.SecuritySchemes = schemeMap(mapField(root, "securityDefinitions"))
+	} else {
+		for _, server := range listField(root, "servers") {
+			spec.Servers = append(spec.Servers, serverURL(toMap(server)))
+		}
+		spec.SecuritySchemes = schemeMap(mapField(mapField(root, "components"), "securitySchemes"))
+	}
+	if requirements := listField(root, "security"); len(requirements) > 0 {
+		for name := range toMap(requirements[0]) {
+			spec.Security = append(spec.Security, name)
+		}
+		sort.Strings(spec.Security)
+	}
+
+	paths := mapField(root, "paths")
+	for _, path := range sortedMapKeys(paths) {
+		item := toMap(paths[path])
+		for _, method := range []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"} {
+			operation := toMap(item[method])
+			if operation == nil {
+				continue
+			}
+			op := &OpenAPIOperation{
+				Method:      strings.ToUpper(method),
+				Path:        path,
+				OperationID: stringField(operation, "operationId"),
+				Summary:     stringField(operation, "summary"),
+				pattern:     pathPattern(path),
+				Responses:   map[string][]OpenAPIContent{},
+			}
+			for _, tag := range listField(operation, "tags") {
+				op.Tags = append(op.Tags, fmt.Sprint(tag))
+			}
+			parameters := mergeParameters(listField(item, "parameters"), listField(operation, "parameters"))
+			if swagger {
+				readSwaggerOperation(op, root, operation, parameters)
+			} else {
+				readOpenAPIOperation(op, operation, parameters)
+			}
+			spec.Operations = append(spec.Operations, op)
+		}
+	}
+	return spec, nil
+}
+
+func readOpenAPIOperation(op *OpenAPIOperation, operation map[string]interface{}, parameters []map[string]interface{}) {
+	for _, parameter := range parameters {
+		op.Parameters = append(op.Parameters, OpenAPIParameter{
+			Name:     stringField(parameter, "name"),
+			In:       stringField(parameter, "in"),
+			Required: parameter["required"] == true,
+			Schema:   mapField(parameter, "schema"),
+			Example:  parameterExample(parameter),
+		})
+	}
+	if body := mapField(operation, "requestBody"); body != nil {
+		contents := openAPIContents(mapField(body, "content"))
+		if len(contents) > 0 {
+			op.Body = &contents[0]
+		}
+	}
+	responses := mapField(operation, "responses")
+	for code, response := range responses {
+		op.Responses[strings.ToUpper(code)] = openAPIContents(mapField(toMap(response), "content"))
+	}
+}
+
+// openAPIContents lists the media types of a content map, json first.
+func openAPIContents(content map[string]interface{}) []OpenAPIContent {
+	var contents []OpenAPIContent
+	for _, mediaType := range sortedMapKeys(content) {
+		media := toMap(content[mediaType])
+		entry := OpenAPIContent{MediaType: mediaType, Schema: mapField(media, "schema"), Example: media["example"]}
+		if entry.Example == nil {
+			for _, name := range sortedMapKeys(mapField(media, "examples")) {
+				entry.Example = toMap(mapField(media, "examples")[name])["value"]
+				break
+			}
+		}
+		if entry.Example == nil && entry.Schema != nil {
+			entry.Example = entry.Schema["example"]
+		}
+		contents = append(contents, entry)
+	}
+	sort.SliceStable(contents, func(i, j int) bool {
+		return isJSONMediaType(contents[i].MediaType) && !isJSONMediaType(contents[j].MediaType)
+	})
+	if len(contents) == 0 {
+		contents = []OpenAPIContent{{}}
+	}
+	return contents
+}
+
+func readSwaggerOperation(op *OpenAPIOperation, root, operation map[string]interface{}, parameters []map[string]interface{}) {
+	consumes := listField(operation, "consumes")
+	if consumes == nil {
+		consumes = listField(root, "consumes")
+	}
+	produces := listField(operation, "produces")
+	if produces == nil {
+		produces = listField(root, "produces")
+	}
+	form := map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+	var formRequired []interface{}
+	for _, parameter := range parameters {
+		in := stringField(parameter, "in")
+		switch in {
+		case "body":
+			op.Body = &OpenAPIContent{MediaType: firstString(consumes, "application/json"), Schema: mapField(parameter, "schema")}
+			if op.Body.Schema != nil {
+				op.Body.Example = op.Body.Schema["example"]
+			}
+		case "formData":
+			form["properties"].(map[string]interface{})[stringField(parameter, "name")] = swaggerParameterSchema(parameter)
+			if parameter["required"] == true {
+				formRequired = append(formRequired, stringField(parameter, "name"))
+			}
+		default:
+			op.Parameters = append(op.Parameters, OpenAPIParameter{
+				Name:     stringField(parameter, "name"),
+				In:       in,
+				Required: parameter["required"] == true,
+				Schema:   swaggerParameterSchema(parameter),
+				Example:  parameterExample(parameter),
+			})
+		}
+	}
+	if len(form["properties"].(map[string]interface{})) > 0 {
+		if formRequired != nil {
+			form["required"] = formRequired
+		}
+		mediaType := "application/x-www-form-urlencoded"
+		for _, c := range consumes {
+			if fmt.Sprint(c) == "multipart/form-data" {
+				mediaType = "multipart/form-data"
+			}
+		}
+		op.Body = &OpenAPIContent{MediaType: mediaType, Schema: form}
+	}
+	for code, response := range mapField(operation, "responses") {
+		response := toMap(response)
+		schema := mapField(response, "schema")
+		if schema == nil {
+			op.Responses[strings.ToUpper(code)] = []OpenAPIContent{{}}
+			continue
+		}
+		var contents []OpenAPIContent
+		examples := mapField(response, "examples")
+		for _, mediaType := range produces {
+			mediaType := fmt.Sprint(mediaType)
+			contents = append(contents, OpenAPIContent{MediaType: mediaType, Schema: schema, Example: examples[mediaType]})
+		}
+		if contents == nil {
+			contents = []OpenAPIContent{{MediaType: "application/json", Schema: schema}}
+		}
+		op.Responses[strings.ToUpper(code)] = contents
+	}
+}
+
+// swaggerParameterSchema is the schema part of a Swagger 2 parameter, which
+// holds type, format and the like next to name and in.
+func swaggerParameterSchema(parameter map[string]interface{}) map[string]interface{} {
+	schema := map[string]interface{}{}
+	for key, value := range parameter {
+		switch key {
+		case "name", "in", "required", "description", "collectionFormat", "allowEmptyValue":
+			continue
+		}
+		schema[key] = value
+	}
+	if schema["type"] == "file" {
+		schema["type"] = "string"
+	}
+	return schema
+}
+
+func swaggerServers(root map[string]interface{}) []string {
+	host := stringField(root, "host")
+	basePath := stringField(root, "basePath")
+	if host == "" {
+		if basePath == "" {
+			return nil
+		}
+		return []string{basePath}
+	}
+	schemes := listField(root, "schemes")
+	if len(schemes) == 0 {
+		schemes = []interface{}{"https"}
+	}
+	var servers []string
+	for _, scheme := range schemes {
+		servers = append(servers, fmt.Sprintf("%s://%s%s", scheme, host, basePath))
+	}
+	return servers
+}
+
+// serverURL fills in the default values of the server variables.
+func serverURL(server map[string]interface{}) string {
+	out := strings.TrimRight(stringField(server, "url"), "/")
+	for name, variable := range mapField(server, "variables") {
+		out = strings.ReplaceAll(out, "{"+name+"}", fmt.Sprint(toMap(variable)["default"]))
+	}
+	return out
+}
+
+// mergeParameters lets operation parameters override the path item ones of
+// the same name and location.
+func mergeParameters(pathLevel, operationLevel []interface{}) []map[string]interface{} {
+	var merged []map[string]interface{}
+	index := map[string]int{}
+	for _, list := range [][]interface{}{pathLevel, operationLevel} {
+		for _, item := range list {
+			parameter := toMap(item)
+			key := stringField(parameter, "in") + ":" + stringField(parameter, "name")
+			if i, ok := index[key]; ok {
+				merged[i] = parameter
+				continue
+			}
+			index[key] = len(merged)
+			merged = append(merged, parameter)
+		}
+	}
+	return merged
+}
+
+func parameterExample(parameter map[string]interface{}) interface{} {
+	if example, ok := parameter["example"]; ok {
+		return example
+	}
+	for _, name := range sortedMapKeys(mapField(parameter, "examples")) {
+		return toMap(mapField(parameter, "examples")[name])["value"]
+	}
+	if schema := mapField(parameter, "schema"); schema != nil {
+		return schema["example"]
+	}
+	return nil
+}
+
+func schemeMap(schemes map[string]interface{}) map[string]map[string]interface{} {
+	out := map[string]map[string]interface{}{}
+	for name, scheme := range schemes {
+		out[name] = toMap(scheme)
+	}
+	return out
+}
+
+// pathTemplate matches a {name} path segment once quoted by regexp.QuoteMeta.
+var pathTemplate = regexp.MustCompile(`\\\{[^/]+?\\\}`)
+
+func pathPattern(path string) *regexp.Regexp {
+	pattern := pathTemplate.ReplaceAllString(regexp.QuoteMeta(path), `[^/]+`)
+	return regexp.MustCompile("^" + pattern + "/?$")
+}
+
+// FindOperation returns the operation documenting a request. The path of the
+// server urls is stripped from the request path first, and literal paths win
+// over templated ones.
+func (spec *OpenAPISpec) FindOperation(method string, requestURL *url.URL) (*OpenAPIOperation, bool) {
+	paths := []string{requestURL.Path}
+	for _, server := range spec.Servers {
+		if base, err := url.Parse(server); err == nil && base.Path != "" && strings.HasPrefix(requestURL.Path, base.Path) {
+			paths = append([]string{strings.TrimPrefix(requestURL.Path, base.Path)}, paths...)
+		}
+	}
+	var found *OpenAPIOperation
+	for _, path := range paths {
+		for _, op := range spec.Operations {
+			if op.Method != strings.ToUpper(method) || !op.pattern.MatchString(path) {
+				continue
+			}
+			if found == nil || strings.Count(op.Path, "{") < strings.Count(found.Path, "{") {
+				found = op
+			}
+		}
+		if found != nil {
+			return found, true
+		}
+	}
+	return nil, false
+}
+
+// Response returns the documented bodies of a status code, falling back on
+// the 2XX style ranges and on default.
+func (op *OpenAPIOperation) Response(status int) ([]OpenAPIContent, bool) {
+	code := fmt.Sprint(status)
+	for _, key := range []string{code, code[:1] + "XX", "DEFAULT"} {
+		if contents, ok := op.Responses[key]; ok {
+			return contents, true
+		}
+	}
+	return nil, false
+}
+
+// SuccessStatus is the first documented 2xx status code, 200 when there is none.
+func (op *OpenAPIOperation) SuccessStatus() int {
+	codes := make([]string, 0, len(op.Responses))
+	for code := range op.Responses {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		var status int
+		if _, err := fmt.Sscanf(code, "%d", &status); err == nil && status >= 200 && status < 300 {
+			return status
+		}
+	}
+	return 200
+}
+
+// JSONSchema converts an OpenAPI schema into a JSON Schema: nullable becomes a
+// null type and the boolean exclusive bounds of OpenAPI 3.0 become numbers.
+func JSONSchema(schema map[string]interface{}) map[string]interface{} {
+	if schema == nil {
+		return nil
+	}
+	out := map[string]interface{}{}
+	for key, value := range schema {
+		switch key {
+		case "nullable", "x-nullable", "discriminator", "xml", "externalDocs", "example":
+			continue
+		case "properties", "patternProperties", "definitions", "$defs":
+			properties := map[string]interface{}{}
+			for name, property := range toMap(value) {
+				properties[name] = JSONSchema(toMap(property))
+			}
+			out[key] = properties
+		case "items", "additionalProperties", "not":
+			if nested := toMap(value); nested != nil {
+				out[key] = JSONSchema(nested)
+			} else {
+				out[key] = value
+			}
+		case "allOf", "anyOf", "oneOf":
+			var list []interface{}
+			for _, item := range toList(value) {
+				list = append(list, JSONSchema(toMap(item)))
+			}
+			out[key] = list
+		default:
+			out[key] = value
+		}
+	}
+	if schema["nullable"] == true || schema["x-nullable"] == true {
+		if kind, ok := out["type"].(string); ok {
+			out["type"] = []interface{}{kind, "null"}
+		}
+	}
+	for _, bound := range []string{"Minimum", "Maximum"} {
+		exclusive, limit := "exclusive"+bound, strings.ToLower(bound)
+		switch out[exclusive] {
+		case true:
+			out[exclusive] = out[limit]
+			delete(out, limit)
+		case false:
+			delete(out, exclusive)
+		}
+	}
+	return out
+}
+
+func isJSONMediaType(mediaType string) bool {
+	mediaType = strings.ToLower(mediaType)
+	return strings.HasPrefix(mediaType, "application/json") || strings.Contains(mediaType, "+json")
+}
+
+// refResolver replaces the local $refs of a document with what they point
+// to. Each $ref is resolved once and shared by every place that uses it, so
+// a schema referenced from many places does not multiply the document. A
+// $ref met again while it is being resolved becomes an empty schema.
+type refResolver struct {
+	root      map[string]interface{}
+	resolved  map[string]interface{}
+	resolving map[string]bool
+}
+
+func resolveRefs(root map[string]interface{}) (map[string]interface{}, error) {
+	resolver := &refResolver{root: root, resolved: map[string]interface{}{}, resolving: map[string]bool{}}
+	out, ok := resolver.resolve(root).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("the document is not an object once its $refs are resolved")
+	}
+	return out, nil
+}
+
+func (resolver *refResolver) resolve(node interface{}) interface{} {
+	switch value := node.(type) {
+	case map[string]interface{}:
+		if ref, ok := value["$ref"].(string); ok {
+			return resolver.ref(ref)
+		}
+		out := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			out[k] = resolver.resolve(v)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(value))
+		for i, v := range value {
+			out[i] = resolver.resolve(v)
+		}
+		return out
+	}
+	return node
+}
+
+func (resolver *refResolver) ref(ref string) interface{} {
+	if resolved, ok := resolver.resolved[ref]; ok {
+		return resolved
+	}
+	if resolver.resolving[ref] || !strings.HasPrefix(ref, "#/") {
+		return map[string]interface{}{}
+	}
+	var target interface{} = resolver.root
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		target = toMap(target)[part]
+	}
+	resolver.resolving[ref] = true
+	resolved := resolver.resolve(target)
+	delete(resolver.resolving, ref)
+	resolver.resolved[ref] = resolved
+	return resolved
+}
+
+// normalizeYAML turns the maps with non-string keys yaml may produce, such
+// as response codes, into string keyed maps.
+func normalizeYAML(node interface{}) interface{} {
+	switch value := node.(type) {
+	case map[string]interface{}:
+		for k, v := range value {
+			value[k] = normalizeYAML(v)
+		}
+		return value
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			out[fmt.Sprint(k)] = normalizeYAML(v)
+		}
+		return out
+	case []interface{}:
+		for i, v := range value {
+			value[i] = normalizeYAML(v)
+		}
+		return value
+	}
+	return node
+}
+
+func toMap(node interface{}) map[string]interface{} {
+	value, _ := node.(map[string]interface{})
+	return value
+}
+
+func toList(node interface{}) []interface{} {
+	value, _ := node.([]interface{})
+	return value
+}
+
+func mapField(node map[string]interface{}, key string) map[string]interface{} {
+	return toMap(node[key])
+}
+
+func listField(node map[string]interface{}, key string) []interface{} {
+	return toList(node[key])
+}
+
+func stringField(node map[string]interface{}, key string) string {
+	if value, ok := node[key]; ok && value != nil {
+		return fmt.Sprint(value)
+	}
+	return ""
+}
+
+func firstString(list []interface{}, fallback string) string {
+	if len(list) == 0 {
+		return fallback
+	}
+	return fmt.Sprint(list[0])
+}
+
+func sortedMapKeys(node map[string]interface{}) []string {
+	keys := make([]string, 0, len(node))
+	for key := range node {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
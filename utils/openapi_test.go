@@ -0,0 +1,364 @@
+package dash
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const petstoreOpenAPI = `
+openapi: 3.0.3
+info: {title: Petstore}
+servers:
+  - url: https://{region}.petstore.test/v1/
+    variables:
+      region: {default: eu}
+paths:
+  /pets:
+    get:
+      tags: [pets]
+      responses:
+        "200":
+          description: ok
+          content:
+            application/json:
+              schema:
+                type: array
+                items: {$ref: '#/components/schemas/Pet'}
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/Pet'}
+      responses:
+        "201": {$ref: '#/paths/~1pets/get/responses/200'}
+  /pets/{id}:
+    parameters:
+      - {name: id, in: path, required: true, schema: {type: integer, example: 7}}
+    get:
+      parameters:
+        - {name: id, in: path, required: true, schema: {type: string}, example: "abc"}
+      responses:
+        "200":
+          description: ok
+          content:
+            application/json:
+              schema: {$ref: '#/components/schemas/Pet'}
+  /pets/mine:
+    get:
+      responses:
+        "204": {description: none}
+components:
+  schemas:
+    Pet:
+      type: object
+      required: [name]
+      properties:
+        name: {type: string}
+        parent: {$ref: '#/components/schemas/Pet'}
+        owner: {$ref: '#/components/schemas/Owner'}
+    Owner:
+      type: object
+      properties:
+        pets:
+          type: array
+          items: {$ref: '#/components/schemas/Pet'}
+`
+
+const petstoreSwagger = `{
+  "swagger": "2.0",
+  "info": {"title": "Petstore"},
+  "host": "petstore.test",
+  "basePath": "/v2",
+  "schemes": ["http", "https"],
+  "consumes": ["application/json"],
+  "produces": ["application/json", "application/xml"],
+  "paths": {
+    "/pets": {
+      "post": {
+        "parameters": [{"name": "pet", "in": "body", "schema": {"$ref": "#/definitions/Pet"}}],
+        "responses": {"200": {"description": "ok", "schema": {"$ref": "#/definitions/Pet"}}}
+      }
+    },
+    "/pets/{id}/photo": {
+      "post": {
+        "consumes": ["multipart/form-data"],
+        "parameters": [
+          {"name": "id", "in": "path", "required": true, "type": "integer"},
+          {"name": "file", "in": "formData", "required": true, "type": "file"},
+          {"name": "caption", "in": "formData", "type": "string", "description": "shown under the photo"}
+        ],
+        "responses": {"204": {"description": "stored"}}
+      }
+    }
+  },
+  "definitions": {
+    "Pet": {"type": "object", "properties": {"name": {"type": "string", "example": "rex"}}, "example": {"name": "rex"}}
+  }
+}`
+
+func loadSpec(t *testing.T, name, content string) *OpenAPISpec {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	spec, err := LoadOpenAPI(path)
+	if err != nil {
+		t.Fatalf("LoadOpenAPI() failed: %v", err)
+	}
+	return spec
+}
+
+func findOperation(t *testing.T, spec *OpenAPISpec, method, path string) *OpenAPIOperation {
+	t.Helper()
+	for _, op := range spec.Operations {
+		if op.Method == method && op.Path == path {
+			return op
+		}
+	}
+	t.Fatalf("no operation %s %s", method, path)
+	return nil
+}
+
+func TestLoadOpenAPIRefs(t *testing.T) {
+	spec := loadSpec(t, "petstore.yaml", petstoreOpenAPI)
+	if !reflect.DeepEqual(spec.Servers, []string{"https://eu.petstore.test/v1"}) {
+		t.Errorf("servers = %v", spec.Servers)
+	}
+
+	get := findOperation(t, spec, "GET", "/pets/{id}")
+	pet := get.Responses["200"][0].Schema
+	properties := toMap(pet["properties"])
+	if properties["name"] == nil || pet["type"] != "object" {
+		t.Fatalf("Pet $ref was not resolved: %v", pet)
+	}
+	// a schema that refers to itself stops at the first repetition, also
+	// through another schema
+	if parent := toMap(properties["parent"]); len(parent) != 0 {
+		t.Errorf("recursive $ref resolved to %v, want an empty schema", parent)
+	}
+	owner := toMap(properties["owner"])
+	if items := toMap(toMap(toMap(owner["properties"])["pets"])["items"]); len(items) != 0 {
+		t.Errorf("indirectly recursive $ref resolved to %v, want an empty schema", items)
+	}
+
+	// the same schema is resolved wherever it is used
+	list := findOperation(t, spec, "GET", "/pets").Responses["200"][0].Schema
+	if !reflect.DeepEqual(toMap(list["items"])["properties"], pet["properties"]) {
+		t.Errorf("shared $ref resolved differently: %v and %v", list["items"], pet)
+	}
+	post := findOperation(t, spec, "POST", "/pets")
+	if post.Body == nil || !reflect.DeepEqual(post.Body.Schema["properties"], pet["properties"]) {
+		t.Errorf("request body $ref resolved to %v", post.Body)
+	}
+	// ~1 in a $ref stands for a slash in the path name
+	if created := post.Responses["201"]; len(created) != 1 || created[0].MediaType != "application/json" || toMap(created[0].Schema["items"])["type"] != "object" {
+		t.Errorf("escaped $ref resolved to %v", created)
+	}
+
+	// operation parameters override the path ones
+	if len(get.Parameters) != 1 || get.Parameters[0].Example != "abc" || get.Parameters[0].Schema["type"] != "string" {
+		t.Errorf("parameters = %+v", get.Parameters)
+	}
+}
+
+func TestResolveRefs(t *testing.T) {
+	schema := map[string]interface{}{"type": "object"}
+	root := map[string]interface{}{
+		"a":           map[string]interface{}{"$ref": "#/definitions/Shared"},
+		"b":           []interface{}{map[string]interface{}{"$ref": "#/definitions/Shared"}},
+		"external":    map[string]interface{}{"$ref": "other.yaml#/Pet"},
+		"missing":     map[string]interface{}{"$ref": "#/definitions/Nope"},
+		"definitions": map[string]interface{}{"Shared": schema},
+	}
+	resolved, err := resolveRefs(root)
+	if err != nil {
+		t.Fatalf("resolveRefs() failed: %v", err)
+	}
+	a := toMap(resolved["a"])
+	b := toMap(toList(resolved["b"])[0])
+	if !reflect.DeepEqual(a, schema) || reflect.ValueOf(a).Pointer() != reflect.ValueOf(b).Pointer() {
+		t.Errorf("a $ref used twice was not resolved once: %v and %v", a, b)
+	}
+	if external := toMap(resolved["external"]); external == nil || len(external) != 0 {
+		t.Errorf("a remote $ref resolved to %v, want an empty schema", resolved["external"])
+	}
+	if resolved["missing"] != nil {
+		t.Errorf("a dangling $ref resolved to %v, want nothing", resolved["missing"])
+	}
+
+	// a document that is itself a $ref to a scalar used to panic
+	for _, document := range []map[string]interface{}{
+		{"$ref": "#/title", "title": "Petstore"},
+		{"$ref": "#/missing"},
+	} {
+		if _, err := resolveRefs(document); err == nil {
+			t.Errorf("resolveRefs(%v) succeeded, want an error", document)
+		}
+	}
+}
+
+// TestResolveRefsDoesNotMultiply resolves a chain of schemas that each use
+// the next one twice, which grows exponentially when every use is copied.
+func TestResolveRefsDoesNotMultiply(t *testing.T) {
+	definitions := map[string]interface{}{"S0": map[string]interface{}{"type": "string"}}
+	for i := 1; i <= 40; i++ {
+		next := map[string]interface{}{"$ref": fmt.Sprintf("#/definitions/S%d", i-1)}
+		definitions[fmt.Sprintf("S%d", i)] = map[string]interface{}{
+			"properties": map[string]interface{}{"left": next, "right": next},
+		}
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := resolveRefs(map[string]interface{}{"definitions": definitions})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("resolveRefs() failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("resolveRefs() copies every use of a shared $ref")
+	}
+}
+
+func TestLoadSwagger(t *testing.T) {
+	spec := loadSpec(t, "petstore.json", petstoreSwagger)
+	if !reflect.DeepEqual(spec.Servers, []string{"http://petstore.test/v2", "https://petstore.test/v2"}) {
+		t.Errorf("servers = %v", spec.Servers)
+	}
+
+	create := findOperation(t, spec, "POST", "/pets")
+	if create.Body == nil || create.Body.MediaType != "application/json" || !reflect.DeepEqual(create.Body.Example, map[string]interface{}{"name": "rex"}) {
+		t.Errorf("body parameter = %+v", create.Body)
+	}
+	if responses := create.Responses["200"]; len(responses) != 2 || responses[1].MediaType != "application/xml" || responses[0].Schema["type"] != "object" {
+		t.Errorf("responses = %+v", responses)
+	}
+
+	photo := findOperation(t, spec, "POST", "/pets/{id}/photo")
+	if len(photo.Parameters) != 1 || photo.Parameters[0].In != "path" || photo.Parameters[0].Schema["type"] != "integer" {
+		t.Errorf("parameters = %+v", photo.Parameters)
+	}
+	if photo.Body == nil || photo.Body.MediaType != "multipart/form-data" {
+		t.Fatalf("formData parameters became %+v", photo.Body)
+	}
+	form := photo.Body.Schema
+	want := map[string]interface{}{
+		"file":    map[string]interface{}{"type": "string"},
+		"caption": map[string]interface{}{"type": "string"},
+	}
+	if !reflect.DeepEqual(form["properties"], want) || !reflect.DeepEqual(form["required"], []interface{}{"file"}) {
+		t.Errorf("form schema = %v", form)
+	}
+	if responses := photo.Responses["204"]; len(responses) != 1 || responses[0].MediaType != "" {
+		t.Errorf("a response without a schema = %+v", responses)
+	}
+}
+
+func TestSuccessStatus(t *testing.T) {
+	tests := []struct {
+		codes []string
+		want  int
+	}{
+		{codes: []string{"404", "201", "200"}, want: 200},
+		{codes: []string{"DEFAULT", "202"}, want: 202},
+		{codes: []string{"400", "DEFAULT"}, want: 200},
+		{codes: []string{"2XX"}, want: 200},
+		{codes: nil, want: 200},
+	}
+	for _, test := range tests {
+		op := &OpenAPIOperation{Responses: map[string][]OpenAPIContent{}}
+		for _, code := range test.codes {
+			op.Responses[code] = nil
+		}
+		if got := op.SuccessStatus(); got != test.want {
+			t.Errorf("SuccessStatus() with %v = %d, want %d", test.codes, got, test.want)
+		}
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema map[string]interface{}
+		want   map[string]interface{}
+	}{
+		{
+			name:   "nullable",
+			schema: map[string]interface{}{"type": "string", "nullable": true, "example": "x"},
+			want:   map[string]interface{}{"type": []interface{}{"string", "null"}},
+		},
+		{
+			name:   "swagger x-nullable",
+			schema: map[string]interface{}{"type": "integer", "x-nullable": true},
+			want:   map[string]interface{}{"type": []interface{}{"integer", "null"}},
+		},
+		{
+			name:   "nullable without a type is left as is",
+			schema: map[string]interface{}{"nullable": true, "enum": []interface{}{"a"}},
+			want:   map[string]interface{}{"enum": []interface{}{"a"}},
+		},
+		{
+			name:   "exclusive bounds",
+			schema: map[string]interface{}{"type": "number", "minimum": 0, "exclusiveMinimum": true, "maximum": 10, "exclusiveMaximum": false},
+			want:   map[string]interface{}{"type": "number", "exclusiveMinimum": 0, "maximum": 10},
+		},
+		{
+			name: "nested schemas",
+			schema: map[string]interface{}{
+				"type":                 "object",
+				"properties":           map[string]interface{}{"age": map[string]interface{}{"type": "integer", "nullable": true}},
+				"additionalProperties": false,
+				"items":                map[string]interface{}{"type": "string", "xml": map[string]interface{}{"name": "tag"}},
+				"oneOf":                []interface{}{map[string]interface{}{"type": "number", "maximum": 5, "exclusiveMaximum": true}},
+			},
+			want: map[string]interface{}{
+				"type":                 "object",
+				"properties":           map[string]interface{}{"age": map[string]interface{}{"type": []interface{}{"integer", "null"}}},
+				"additionalProperties": false,
+				"items":                map[string]interface{}{"type": "string"},
+				"oneOf":                []interface{}{map[string]interface{}{"type": "number", "exclusiveMaximum": 5}},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := JSONSchema(test.schema); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("JSONSchema() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFindOperation(t *testing.T) {
+	spec := loadSpec(t, "petstore.yaml", petstoreOpenAPI)
+	tests := []struct {
+		method string
+		url    string
+		path   string
+	}{
+		{method: "GET", url: "https://eu.petstore.test/v1/pets", path: "/pets"},
+		{method: "get", url: "https://eu.petstore.test/v1/pets/12/", path: "/pets/{id}"},
+		{method: "GET", url: "https://eu.petstore.test/v1/pets/mine", path: "/pets/mine"},
+		{method: "GET", url: "http://localhost:8080/pets/12", path: "/pets/{id}"},
+		{method: "DELETE", url: "https://eu.petstore.test/v1/pets/12"},
+		{method: "GET", url: "https://eu.petstore.test/v1/pets/12/photos"},
+	}
+	for _, test := range tests {
+		requestURL, _ := url.Parse(test.url)
+		op, ok := spec.FindOperation(test.method, requestURL)
+		switch {
+		case test.path == "" && ok:
+			t.Errorf("FindOperation(%s %s) = %s, want none", test.method, test.url, op.Path)
+		case test.path != "" && (!ok || op.Path != test.path):
+			t.Errorf("FindOperation(%s %s) = %v, want %s", test.method, test.url, op, test.path)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package dash
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Poll struct
+// A scenario with a poll section is sent again every Interval until its
+// validators pass, MaxAttempts requests were made or MaxDuration has passed.
+type Poll struct {
+	Interval    string
+	MaxDuration string
+	MaxAttempts int
+}
+
+const (
+	defaultPollInterval    = time.Second
+	defaultPollMaxDuration = 30 * time.Second
+)
+
+func (poll Poll) isSet() bool {
+	return poll.Interval != "" || poll.MaxDuration != "" || poll.MaxAttempts != 0
+}
+
+// limits returns the poll interval and deadline. Without MaxAttempts the
+// polling stops after 30s unless MaxDuration says otherwise.
+func (poll Poll) limits(start time.Time) (time.Duration, time.Time, error) {
+	interval, err := parseDuration(poll.Interval)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("poll interval: %v", err)
+	}
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	maxDuration, err := parseDuration(poll.MaxDuration)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("poll maxduration: %v", err)
+	}
+	if maxDuration <= 0 && poll.MaxAttempts <= 0 {
+		maxDuration = defaultPollMaxDuration
+	}
+	var deadline time.Time
+	if maxDuration > 0 {
+		deadline = start.Add(maxDuration)
+	}
+	return interval, deadline, nil
+}
+
+// poll sends the request once, or with a poll section until its validators
+// pass. The outcome of the last request is the one reported.
+func (scenario *Scenario) poll(client *http.Client, request *http.Request) {
+	if !scenario.Poll.isSet() {
+		scenario.send(client, request)
+		return
+	}
+	start := time.Now()
+	interval, deadline, err := scenario.Poll.limits(start)
+	if err != nil {
+		errorReporterWithReason(err, scenario, "Invalid poll")
+		return
+	}
+	for polls := 1; ; polls++ {
+		scenario.Polls = polls
+		scenario.Response, scenario.ErrorOutcome, scenario.ValidateOutcome = nil, nil, nil
+		try := request
+		if polls > 1 {
+			if try, err = cloneRequest(request); err != nil {
+				errorReporter(err, scenario)
+				return
+			}
+		}
+		scenario.send(client, try)
+		if runCancelled() {
+			return
+		}
+		passed := scenario.ValidateOutcome != nil && scenario.ValidateOutcome.Failed == 0
+		var stop string
+		switch {
+		case passed:
+			stop = "until validators passed"
+		case scenario.Poll.MaxAttempts > 0 && polls >= scenario.Poll.MaxAttempts:
+			stop = fmt.Sprintf("and validators still fail after maxattempts %d", scenario.Poll.MaxAttempts)
+		case !deadline.IsZero() && time.Now().Add(interval).After(deadline):
+			stop = fmt.Sprintf("and validators still fail at maxduration %s", deadline.Sub(start))
+		}
+		if stop == "" {
+			select {
+			case <-time.After(interval):
+			case <-runContext.Done():
+				cancelScenario(scenario)
+				return
+			}
+			continue
+		}
+		polled := fmt.Sprintf("Polled -- %d requests in %s %s", polls, time.Since(start).Round(time.Millisecond), stop)
+		if scenario.ValidateOutcome != nil {
+			scenario.ValidateOutcome.Actual += polled + "\n"
+		} else if scenario.ErrorOutcome != nil {
+			scenario.ErrorOutcome.ErrorDesc += " (" + polled + ")"
+		}
+		return
+	}
+}
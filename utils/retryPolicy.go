@@ -0,0 +1,208 @@
+package dash
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Retry struct
+// MaxAttempts counts the first request, so 1 disables retries. Backoff is
+// constant, linear or exponential from Interval up to MaxInterval. A request
+// is retried when its status is in StatusCodes or its error matches one of
+// OnErrors (timeout, connection, reset, eof, dns or any). Only the Methods
+// listed are retried; by default those are the idempotent ones.
+type Retry struct {
+	MaxAttempts int
+	Backoff     string
+	Interval    string
+	MaxInterval string
+	StatusCodes []int
+	OnErrors    []string
+	Methods     []string
+}
+
+var (
+	idempotentMethods = []string{"GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE"}
+	// defaultRetry is used when neither the scenario, its service nor the
+	// config has a retry section.
+	defaultRetry = Retry{
+		MaxAttempts: 3,
+		Backoff:     "exponential",
+		Interval:    "1s",
+		MaxInterval: "30s",
+		StatusCodes: []int{429, 500, 502, 503, 504},
+		OnErrors:    []string{"connection", "reset", "eof", "dns"},
+	}
+)
+
+// retryPolicy is a Retry with its durations parsed.
+type retryPolicy struct {
+	Retry
+	interval    time.Duration
+	maxInterval time.Duration
+}
+
+func (retry Retry) isSet() bool {
+	return retry.MaxAttempts != 0 || retry.Backoff != "" || retry.Interval != "" || retry.MaxInterval != "" ||
+		len(retry.StatusCodes) != 0 || len(retry.OnErrors) != 0 || len(retry.Methods) != 0
+}
+
+// newRetryPolicy fills the unset fields of a retry section from the defaults.
+func newRetryPolicy(retry Retry) (retryPolicy, error) {
+	if retry.MaxAttempts == 0 {
+		retry.MaxAttempts = defaultRetry.MaxAttempts
+	}
+	if retry.Backoff == "" {
+		retry.Backoff = defaultRetry.Backoff
+	}
+	switch strings.ToLower(retry.Backoff) {
+	case "constant", "linear", "exponential":
+	default:
+		return retryPolicy{}, fmt.Errorf("unknown retry backoff '%s', use constant, linear or exponential", retry.Backoff)
+	}
+	if retry.Interval == "" {
+		retry.Interval = defaultRetry.Interval
+	}
+	if retry.MaxInterval == "" {
+		retry.MaxInterval = defaultRetry.MaxInterval
+	}
+	if retry.StatusCodes == nil {
+		retry.StatusCodes = defaultRetry.StatusCodes
+	}
+	if retry.OnErrors == nil {
+		retry.OnErrors = defaultRetry.OnErrors
+	}
+	if retry.Methods == nil {
+		retry.Methods = idempotentMethods
+	}
+	interval, err := parseDuration(retry.Interval)
+	if err != nil {
+		return retryPolicy{}, fmt.Errorf("retry interval: %v", err)
+	}
+	maxInterval, err := parseDuration(retry.MaxInterval)
+	if err != nil {
+		return retryPolicy{}, fmt.Errorf("retry maxinterval: %v", err)
+	}
+	return retryPolicy{Retry: retry, interval: interval, maxInterval: maxInterval}, nil
+}
+
+// attempts is the number of times a request with the given method may be sent.
+func (policy retryPolicy) attempts(method string) int {
+	if policy.MaxAttempts < 1 || !stringInSlice(strings.ToUpper(method), upper(policy.Methods)) {
+		return 1
+	}
+	return policy.MaxAttempts
+}
+
+// retryable tells whether the outcome of an attempt is worth another one.
+func (policy retryPolicy) retryable(response *http.Response, err error) bool {
+	if err != nil {
+		for _, kind := range policy.OnErrors {
+			if errorIs(err, kind) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, code := range policy.StatusCodes {
+		if response.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// wait is the pause before the given retry (1 for the first one). A
+// Retry-After header in seconds is honoured up to MaxInterval.
+func (policy retryPolicy) wait(retry int, response *http.Response) time.Duration {
+	wait := policy.interval
+	switch strings.ToLower(policy.Backoff) {
+	case "linear":
+		wait = policy.interval * time.Duration(retry)
+	case "exponential":
+		for i := 1; i < retry && wait < policy.maxInterval; i++ {
+			wait *= 2
+		}
+	}
+	if response != nil {
+		if seconds, err := strconv.Atoi(response.Header.Get("Retry-After")); err == nil {
+			if after := time.Duration(seconds) * time.Second; after > wait {
+				wait = after
+			}
+		}
+	}
+	if policy.maxInterval > 0 && wait > policy.maxInterval {
+		wait = policy.maxInterval
+	}
+	return wait
+}
+
+func errorIs(err error, kind string) bool {
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	switch strings.ToLower(kind) {
+	case "any":
+		return true
+	case "timeout":
+		return isTimeout(err)
+	case "connection":
+		return errors.Is(err, syscall.ECONNREFUSED) || (errors.As(err, &opErr) && opErr.Op == "dial" && !isTimeout(err))
+	case "reset":
+		return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE)
+	case "eof":
+		return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+	case "dns":
+		return errors.As(err, &dnsErr)
+	}
+	return false
+}
+
+func upper(values []string) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = strings.ToUpper(v)
+	}
+	return out
+}
+
+// sendWithRetry sends the request through send until it succeeds, is not
+// retryable or runs out of attempts. The scenario timeout bounds all attempts
+// together. scenario.Attempts records how many requests were made.
+func (scenario *Scenario) sendWithRetry(request *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	policy, err := newRetryPolicy(scenario.Retry)
+	if err != nil {
+		return nil, err
+	}
+	attempts := policy.attempts(request.Method)
+	for attempt := 1; ; attempt++ {
+		scenario.Attempts = attempt
+		try := request
+		if attempt > 1 {
+			if try, err = cloneRequest(request); err != nil {
+				return nil, err
+			}
+		}
+		response, err := send(try)
+		if attempt >= attempts || !policy.retryable(response, err) || request.Context().Err() != nil {
+			return response, err
+		}
+		wait := policy.wait(attempt, response)
+		if response != nil {
+			io.Copy(ioutil.Discard, response.Body)
+			response.Body.Close()
+		}
+		select {
+		case <-time.After(wait):
+		case <-request.Context().Done():
+			return nil, request.Context().Err()
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestNewRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		retry       Retry
+		attempts    int
+		backoff     string
+		interval    time.Duration
+		maxInterval time.Duration
+		err         string
+	}{
+		{name: "defaults", retry: Retry{}, attempts: 3, backoff: "exponential", interval: time.Second, maxInterval: 30 * time.Second},
+		{name: "overrides", retry: Retry{MaxAttempts: 5, Backoff: "Linear", Interval: "200ms", MaxInterval: "2"}, attempts: 5, backoff: "Linear", interval: 200 * time.Millisecond, maxInterval: 2 * time.Second},
+		{name: "unknown backoff", retry: Retry{Backoff: "random"}, err: "unknown retry backoff 'random'"},
+		{name: "invalid interval", retry: Retry{Interval: "soon"}, err: "retry interval"},
+		{name: "invalid max interval", retry: Retry{MaxInterval: "later"}, err: "retry maxinterval"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy, err := newRetryPolicy(test.retry)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("newRetryPolicy() error = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newRetryPolicy() failed: %v", err)
+			}
+			if policy.MaxAttempts != test.attempts || policy.Backoff != test.backoff || policy.interval != test.interval || policy.maxInterval != test.maxInterval {
+				t.Errorf("newRetryPolicy() = %d %s %v %v, want %d %s %v %v", policy.MaxAttempts, policy.Backoff, policy.interval, policy.maxInterval,
+					test.attempts, test.backoff, test.interval, test.maxInterval)
+			}
+		})
+	}
+}
+
+func TestRetryAttempts(t *testing.T) {
+	tests := []struct {
+		name   string
+		retry  Retry
+		method string
+		want   int
+	}{
+		{name: "get is idempotent", retry: Retry{}, method: "GET", want: 3},
+		{name: "put is idempotent", retry: Retry{MaxAttempts: 4}, method: "PUT", want: 4},
+		{name: "delete is idempotent", retry: Retry{}, method: "delete", want: 3},
+		{name: "post is not retried", retry: Retry{}, method: "POST", want: 1},
+		{name: "patch is not retried", retry: Retry{}, method: "PATCH", want: 1},
+		{name: "post when listed", retry: Retry{Methods: []string{"post"}}, method: "POST", want: 3},
+		{name: "get when not listed", retry: Retry{Methods: []string{"POST"}}, method: "GET", want: 1},
+		{name: "single attempt", retry: Retry{MaxAttempts: 1}, method: "GET", want: 1},
+		{name: "negative attempts", retry: Retry{MaxAttempts: -2}, method: "GET", want: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy, err := newRetryPolicy(test.retry)
+			if err != nil {
+				t.Fatalf("newRetryPolicy() failed: %v", err)
+			}
+			if got := policy.attempts(test.method); got != test.want {
+				t.Errorf("attempts(%s) = %d, want %d", test.method, got, test.want)
+			}
+		})
+	}
+}
+
+// TestRetryWait lists the pauses before the first retries of each policy.
+func TestRetryWait(t *testing.T) {
+	s := time.Second
+	policies := map[string]struct {
+		retry Retry
+		waits []time.Duration
+	}{
+		"constant":                  {Retry{Backoff: "constant", Interval: "1s"}, []time.Duration{s, s, s, s}},
+		"linear":                    {Retry{Backoff: "linear", Interval: "1s"}, []time.Duration{s, 2 * s, 3 * s, 4 * s}},
+		"linear up to the max":      {Retry{Backoff: "LINEAR", Interval: "2s", MaxInterval: "5s"}, []time.Duration{2 * s, 4 * s, 5 * s, 5 * s}},
+		"exponential by default":    {Retry{Interval: "500ms"}, []time.Duration{s / 2, s, 2 * s, 4 * s, 8 * s}},
+		"exponential up to the max": {Retry{Interval: "1s", MaxInterval: "5s"}, []time.Duration{s, 2 * s, 4 * s, 5 * s, 5 * s}},
+		"max below the interval":    {Retry{Backoff: "constant", Interval: "3s", MaxInterval: "1s"}, []time.Duration{s, s}},
+	}
+	for name, policy := range policies {
+		t.Run(name, func(t *testing.T) {
+			retryPolicy, err := newRetryPolicy(policy.retry)
+			if err != nil {
+				t.Fatalf("newRetryPolicy() failed: %v", err)
+			}
+			for i, want := range policy.waits {
+				if got := retryPolicy.wait(i+1, nil); got != want {
+					t.Errorf("wait before retry %d = %v, want %v", i+1, got, want)
+				}
+			}
+		})
+	}
+	// a long exponential run must not overflow past the cap
+	retryPolicy, _ := newRetryPolicy(Retry{Interval: "1s", MaxInterval: "10s"})
+	if got := retryPolicy.wait(100, nil); got != 10*s {
+		t.Errorf("wait before retry 100 = %v, want the 10s cap", got)
+	}
+}
+
+func TestRetryWaitRetryAfter(t *testing.T) {
+	retryPolicy, _ := newRetryPolicy(Retry{Interval: "1s", MaxInterval: "30s"})
+	tests := []struct {
+		retryAfter string
+		retry      int
+		want       time.Duration
+	}{
+		{retryAfter: "4", retry: 1, want: 4 * time.Second},
+		{retryAfter: "1", retry: 3, want: 4 * time.Second},
+		{retryAfter: "120", retry: 1, want: 30 * time.Second},
+		{retryAfter: "Wed, 21 Oct 2015 07:28:00 GMT", retry: 1, want: time.Second},
+		{retryAfter: "", retry: 2, want: 2 * time.Second},
+	}
+	for _, test := range tests {
+		response := &http.Response{Header: http.Header{}}
+		if test.retryAfter != "" {
+			response.Header.Set("Retry-After", test.retryAfter)
+		}
+		if got := retryPolicy.wait(test.retry, response); got != test.want {
+			t.Errorf("wait(%d) with Retry-After %q = %v, want %v", test.retry, test.retryAfter, got, test.want)
+		}
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	tests := []struct {
+		name     string
+		retry    Retry
+		response *http.Response
+		err      error
+		want     bool
+	}{
+		{name: "503", retry: Retry{}, response: &http.Response{StatusCode: 503}, want: true},
+		{name: "429", retry: Retry{}, response: &http.Response{StatusCode: 429}, want: true},
+		{name: "200", retry: Retry{}, response: &http.Response{StatusCode: 200}, want: false},
+		{name: "404", retry: Retry{}, response: &http.Response{StatusCode: 404}, want: false},
+		{name: "listed status", retry: Retry{StatusCodes: []int{404}}, response: &http.Response{StatusCode: 404}, want: true},
+		{name: "status not listed", retry: Retry{StatusCodes: []int{404}}, response: &http.Response{StatusCode: 503}, want: false},
+		{name: "connection refused", retry: Retry{}, err: dialErr, want: true},
+		{name: "connection reset", retry: Retry{}, err: fmt.Errorf("read: %w", syscall.ECONNRESET), want: true},
+		{name: "unexpected eof", retry: Retry{}, err: fmt.Errorf("read: %w", io.ErrUnexpectedEOF), want: true},
+		{name: "dns", retry: Retry{}, err: &net.DNSError{Err: "no such host", Name: "nowhere.invalid"}, want: true},
+		{name: "timeout is not retried by default", retry: Retry{}, err: context.DeadlineExceeded, want: false},
+		{name: "timeout when listed", retry: Retry{OnErrors: []string{"timeout"}}, err: context.DeadlineExceeded, want: true},
+		{name: "other error", retry: Retry{}, err: errors.New("tls: bad certificate"), want: false},
+		{name: "any error", retry: Retry{OnErrors: []string{"any"}}, err: errors.New("tls: bad certificate"), want: true},
+		{name: "no errors listed", retry: Retry{OnErrors: []string{}}, err: dialErr, want: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy, err := newRetryPolicy(test.retry)
+			if err != nil {
+				t.Fatalf("newRetryPolicy() failed: %v", err)
+			}
+			if got := policy.retryable(test.response, test.err); got != test.want {
+				t.Errorf("retryable() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSendWithRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		statuses []int
+		attempts int
+		status   int
+	}{
+		{name: "success", method: "GET", statuses: []int{200}, attempts: 1, status: 200},
+		{name: "retried until success", method: "GET", statuses: []int{503, 502, 200}, attempts: 3, status: 200},
+		{name: "out of attempts", method: "GET", statuses: []int{503, 503, 503, 200}, attempts: 3, status: 503},
+		{name: "not retryable", method: "GET", statuses: []int{400, 200}, attempts: 1, status: 400},
+		{name: "post is sent once", method: "POST", statuses: []int{503, 200}, attempts: 1, status: 503},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scenario := Scenario{Retry: Retry{Interval: "1ms"}}
+			request, err := http.NewRequest(test.method, "http://127.0.0.1/", strings.NewReader("payload"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			sent := 0
+			send := func(request *http.Request) (*http.Response, error) {
+				body, _ := ioutil.ReadAll(request.Body)
+				if string(body) != "payload" {
+					t.Errorf("attempt %d sent body %q, want the original body", sent+1, body)
+				}
+				status := test.statuses[sent]
+				sent++
+				return &http.Response{StatusCode: status, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+			}
+			response, err := scenario.sendWithRetry(request, send)
+			if err != nil {
+				t.Fatalf("sendWithRetry() failed: %v", err)
+			}
+			if response.StatusCode != test.status || sent != test.attempts || scenario.Attempts != test.attempts {
+				t.Errorf("sendWithRetry() = status %d after %d requests (%d recorded), want %d after %d", response.StatusCode, sent, scenario.Attempts, test.status, test.attempts)
+			}
+		})
+	}
+}
+
+func TestSendWithRetryCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	request, err := http.NewRequestWithContext(ctx, "GET", "http://127.0.0.1/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scenario := Scenario{Retry: Retry{Interval: "1m"}}
+	sent := 0
+	send := func(*http.Request) (*http.Response, error) {
+		sent++
+		time.AfterFunc(10*time.Millisecond, cancel)
+		return &http.Response{StatusCode: 503, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+	}
+	start := time.Now()
+	_, err = scenario.sendWithRetry(request, send)
+	if !errors.Is(err, context.Canceled) || sent != 1 {
+		t.Errorf("sendWithRetry() = %v after %d requests, want context.Canceled after 1", err, sent)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("sendWithRetry() waited %v after the cancellation", elapsed)
+	}
+}
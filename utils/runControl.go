@@ -0,0 +1,140 @@
+package dash
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Process exit codes. Errors win over failures: a scenario that could not be
+// evaluated says less about the service than one that failed its checks.
+const (
+	ExitPassed = 0
+	ExitFailed = 1
+	ExitErrors = 2
+	ExitConfig = 3
+)
+
+// ExitConfigError logs why the flags, configs or scenarios cannot be used
+// and exits with ExitConfig.
+func ExitConfigError(args ...interface{}) {
+	log.Errorln(args...)
+	os.Exit(ExitConfig)
+}
+
+// RunOptions struct
+// FailFast stops the run after the first failed or errored scenario,
+// MaxFailures after that many. Scenarios in flight are cancelled and the
+// ones not started yet are reported as cancelled; teardown still runs.
+type RunOptions struct {
+	Parallel    int
+	FailFast    bool
+	MaxFailures int
+}
+
+// RunSummary counts the outcomes of the test scenarios of a run.
+// Interrupted is set when the run was stopped by SIGINT or SIGTERM.
+type RunSummary struct {
+	Passed      int
+	Failed      int
+	Errors      int
+	Skipped     int
+	Cancelled   int
+	Interrupted bool
+}
+
+var (
+	// runContext is cancelled when a fail-fast threshold is reached. Every
+	// scenario request is bound to it.
+	runContext, cancelRun = context.WithCancel(context.Background())
+	// interrupted is 1 once a signal cancelled the run.
+	interrupted int32
+)
+
+// CancelOnInterrupt stops the run on SIGINT or SIGTERM the way fail-fast
+// does: requests in flight are cancelled, the scenarios not started yet are
+// reported as cancelled, and teardown and the reports still run. A second
+// signal exits at once.
+func CancelOnInterrupt() {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		atomic.StoreInt32(&interrupted, 1)
+		log.Warn("Interrupted, stopping the run. Teardown and reports still run, interrupt again to exit now.")
+		cancelRun()
+		<-signals
+		os.Exit(ExitErrors)
+	}()
+}
+
+func runInterrupted() bool {
+	return atomic.LoadInt32(&interrupted) == 1
+}
+
+// failureLimit is the number of failed scenarios that stops the run, 0 for none.
+func (options RunOptions) failureLimit() int {
+	if options.FailFast {
+		return 1
+	}
+	if options.MaxFailures > 0 {
+		return options.MaxFailures
+	}
+	return 0
+}
+
+func (summary *RunSummary) add(report ReportTemplate) {
+	switch report.FinalTestStatus {
+	case "passed":
+		summary.Passed++
+	case "failed":
+		summary.Failed++
+	case "skipped":
+		summary.Skipped++
+	case "cancelled":
+		summary.Cancelled++
+	default:
+		summary.Errors++
+	}
+}
+
+// ExitCode is the process exit code for the run.
+func (summary RunSummary) ExitCode() int {
+	if summary.Errors > 0 || summary.Interrupted {
+		return ExitErrors
+	}
+	if summary.Failed > 0 {
+		return ExitFailed
+	}
+	return ExitPassed
+}
+
+func (summary RunSummary) String() string {
+	return fmt.Sprintf("%d passed, %d failed, %d errors, %d skipped, %d cancelled",
+		summary.Passed, summary.Failed, summary.Errors, summary.Skipped, summary.Cancelled)
+}
+
+func runCancelled() bool {
+	return runContext.Err() != nil
+}
+
+// cancelScenario marks a scenario stopped by fail-fast as cancelled.
+func cancelScenario(scenario *Scenario) {
+	scenario.Response, scenario.ErrorOutcome = nil, nil
+	scenario.ValidateOutcome = &ValidateOutcome{
+		FinalStatus: "cancelled",
+		Actual:      fmt.Sprintln(cancelReason()),
+	}
+}
+
+func cancelReason() string {
+	if runInterrupted() {
+		return "Cancelled -- the run was interrupted"
+	}
+	return "Cancelled -- the run was stopped after too many failures"
+}
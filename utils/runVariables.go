@@ -0,0 +1,64 @@
+package dash
+
+import (
+	"fmt"
+	"sync"
+)
+
+// RunVariables holds the values captured from responses during a run. They
+// are shared by every worker, so all access goes through the lock.
+type RunVariables struct {
+	lock   sync.RWMutex
+	values map[string]string
+}
+
+var runVariables = &RunVariables{values: map[string]string{}}
+
+// Set stores a captured value under name.
+func (v *RunVariables) Set(name, value string) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	v.values[name] = value
+}
+
+// Get returns a captured value and whether it exists.
+func (v *RunVariables) Get(name string) (string, bool) {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+	value, ok := v.values[name]
+	return value, ok
+}
+
+// withRunVariables returns a copy of config whose Data also contains every
+// value captured so far. Captured values win over static data of the same name.
+func withRunVariables(config Config) Config {
+	runVariables.lock.RLock()
+	defer runVariables.lock.RUnlock()
+	return withData(config, runVariables.values)
+}
+
+// withExampleData returns a copy of config whose Data also contains the
+// columns of the examples row the scenario was expanded from.
+func withExampleData(config Config, scenario Scenario) Config {
+	return withData(config, scenario.ExampleRow)
+}
+
+func withData(config Config, values map[string]string) Config {
+	if len(values) == 0 {
+		return config
+	}
+	data := make(map[string]string, len(config.Data)+len(values))
+	for k, v := range config.Data {
+		data[k] = v
+	}
+	for k, v := range values {
+		data[k] = v
+	}
+	config.Data = data
+	return config
+}
+
+// ExampleName is the scenario name given to one row of an examples table.
+func ExampleName(name string, row int) string {
+	return fmt.Sprintf("%s [row %d]", name, row)
+}
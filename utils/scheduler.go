@@ -0,0 +1,182 @@
+package dash
+
+import (
+	"fmt"
+	"strings"
+)
+
+// scenarioGraph is the dependency graph built from the depends_on fields.
+// Scenarios are referenced by name, so a dependency on a replicated scenario
+// waits for every replica, and one on an examples scenario for every row.
+type scenarioGraph struct {
+	scenarios  []Scenario
+	upstream   [][]int
+	downstream [][]int
+}
+
+type graphResult struct {
+	index    int
+	scenario Scenario
+}
+
+func newScenarioGraph(scenarios []Scenario) (*scenarioGraph, error) {
+	graph := &scenarioGraph{
+		scenarios:  scenarios,
+		upstream:   make([][]int, len(scenarios)),
+		downstream: make([][]int, len(scenarios)),
+	}
+	byName := indexByName(scenarios)
+	for i, scenario := range scenarios {
+		for _, name := range scenario.DependsOn {
+			targets, ok := byName[name]
+			if !ok {
+				return nil, fmt.Errorf("scenario '%s' depends on unknown scenario '%s'", scenario.Scenario, name)
+			}
+			for _, t := range targets {
+				if t == i {
+					return nil, fmt.Errorf("scenario '%s' depends on itself", scenario.Scenario)
+				}
+				graph.upstream[i] = append(graph.upstream[i], t)
+				graph.downstream[t] = append(graph.downstream[t], i)
+			}
+		}
+	}
+	return graph, graph.checkCycles()
+}
+
+// CheckDependencies validates the depends_on of the scenarios: unknown
+// names, self references and cycles. It is called before the init functions
+// so an invalid suite stops before any test data is created.
+func CheckDependencies(scenarios []Scenario) error {
+	_, err := newScenarioGraph(scenarios)
+	return err
+}
+
+// indexByName maps each scenario name to the scenarios it refers to in
+// depends_on: an examples scenario is also known by its name without the row.
+func indexByName(scenarios []Scenario) map[string][]int {
+	byName := map[string][]int{}
+	for i, scenario := range scenarios {
+		byName[scenario.Scenario] = append(byName[scenario.Scenario], i)
+		if scenario.Row > 0 {
+			base := strings.TrimSuffix(scenario.Scenario, ExampleName("", scenario.Row))
+			byName[base] = append(byName[base], i)
+		}
+	}
+	return byName
+}
+
+// checkCycles runs a dry topological sort and names the scenarios that could
+// never be released.
+func (graph *scenarioGraph) checkCycles() error {
+	pending := make([]int, len(graph.scenarios))
+	var ready []int
+	for i := range graph.scenarios {
+		pending[i] = len(graph.upstream[i])
+		if pending[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+	visited := 0
+	for len(ready) > 0 {
+		i := ready[0]
+		ready = ready[1:]
+		visited++
+		for _, d := range graph.downstream[i] {
+			pending[d]--
+			if pending[d] == 0 {
+				ready = append(ready, d)
+			}
+		}
+	}
+	if visited == len(graph.scenarios) {
+		return nil
+	}
+	var names []string
+	for i, p := range pending {
+		if p > 0 {
+			names = append(names, graph.scenarios[i].Scenario)
+		}
+	}
+	return fmt.Errorf("depends_on cycle between scenarios: %s", strings.Join(names, ", "))
+}
+
+// run executes the graph on a pool of parallel workers. Independent scenarios
+// run concurrently; a scenario is released once all of its upstream scenarios
+// have finished, and is reported as skipped when any of them did not pass.
+func (graph *scenarioGraph) run(config Config, parallel int, finalScenarios chan Scenario) {
+	total := len(graph.scenarios)
+	jobs := make(chan graphResult, total)
+	done := make(chan graphResult, total)
+	for w := 0; w < parallel; w++ {
+		go func() {
+			out := make(chan Scenario, 1)
+			for job := range jobs {
+				if runCancelled() {
+					scenario := describeScenario(job.scenario, config)
+					cancelScenario(&scenario)
+					done <- graphResult{index: job.index, scenario: scenario}
+					continue
+				}
+				Worker(job.scenario, config, out)
+				done <- graphResult{index: job.index, scenario: <-out}
+			}
+		}()
+	}
+
+	pending := make([]int, total)
+	blockedBy := make([]string, total)
+	for i := range graph.scenarios {
+		pending[i] = len(graph.upstream[i])
+		if pending[i] == 0 {
+			jobs <- graphResult{index: i, scenario: graph.scenarios[i]}
+		}
+	}
+	for finished := 0; finished < total; {
+		result := <-done
+		queue := []graphResult{result}
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+			finished++
+			finalScenarios <- current.scenario
+			passed := current.scenario.ValidateOutcome != nil && current.scenario.ValidateOutcome.FinalStatus == "passed"
+			for _, d := range graph.downstream[current.index] {
+				if !passed && blockedBy[d] == "" {
+					blockedBy[d] = current.scenario.Scenario
+				}
+				pending[d]--
+				if pending[d] != 0 {
+					continue
+				}
+				if blockedBy[d] != "" {
+					reason := fmt.Sprintf("upstream scenario '%s' did not pass", blockedBy[d])
+					queue = append(queue, graphResult{index: d, scenario: skipScenario(graph.scenarios[d], config, reason)})
+				} else {
+					jobs <- graphResult{index: d, scenario: graph.scenarios[d]}
+				}
+			}
+		}
+	}
+	close(jobs)
+}
+
+// skipScenario marks a scenario that will not be executed as skipped.
+func skipScenario(scenario Scenario, config Config, reason string) Scenario {
+	scenario = describeScenario(scenario, config)
+	scenario.ValidateOutcome = &ValidateOutcome{
+		FinalStatus: "skipped",
+		Actual:      fmt.Sprintln("Skipped --", reason),
+	}
+	return scenario
+}
+
+// describeScenario resolves the service details and url of a scenario that
+// is reported without being executed.
+func describeScenario(scenario Scenario, config Config) Scenario {
+	isolate(&scenario)
+	getService(&scenario, config)
+	urlConfigs(&scenario, config)
+	scenario.MaskedFields = config.MaskedFields
+	return scenario
+}
@@ -0,0 +1,172 @@
+package dash
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// graphOf builds scenarios from "name: upstream, upstream" specs.
+func graphOf(specs ...string) []Scenario {
+	var scenarios []Scenario
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, ":", 2)
+		scenario := Scenario{Scenario: strings.TrimSpace(parts[0])}
+		if len(parts) == 2 {
+			for _, name := range strings.Split(parts[1], ",") {
+				scenario.DependsOn = append(scenario.DependsOn, strings.TrimSpace(name))
+			}
+		}
+		scenarios = append(scenarios, scenario)
+	}
+	return scenarios
+}
+
+func TestNewScenarioGraph(t *testing.T) {
+	tests := []struct {
+		name  string
+		specs []string
+		err   string
+	}{
+		{name: "independent", specs: []string{"a", "b"}},
+		{name: "chain declared backwards", specs: []string{"c: b", "b: a", "a"}},
+		{name: "diamond", specs: []string{"a", "b: a", "c: a", "d: b, c"}},
+		{name: "replicas share a name", specs: []string{"a", "a", "b: a"}},
+		{name: "unknown upstream", specs: []string{"a: missing"}, err: "scenario 'a' depends on unknown scenario 'missing'"},
+		{name: "self reference", specs: []string{"a: a"}, err: "scenario 'a' depends on itself"},
+		{name: "replica depending on its own name", specs: []string{"a", "a: a"}, err: "scenario 'a' depends on itself"},
+		{name: "two scenario cycle", specs: []string{"a: b", "b: a"}, err: "depends_on cycle between scenarios: a, b"},
+		{name: "cycle behind a passing root", specs: []string{"root", "a: root, c", "b: a", "c: b", "leaf: root"}, err: "depends_on cycle between scenarios: a, b, c"},
+		{name: "scenarios stuck behind a cycle", specs: []string{"a: b", "b: a", "d: a"}, err: "depends_on cycle between scenarios: a, b, d"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			graph, err := newScenarioGraph(graphOf(test.specs...))
+			switch {
+			case test.err == "" && err != nil:
+				t.Errorf("newScenarioGraph() failed: %v", err)
+			case test.err != "" && (err == nil || err.Error() != test.err):
+				t.Errorf("newScenarioGraph() error = %v, want %q", err, test.err)
+			case test.err == "" && len(graph.scenarios) != len(test.specs):
+				t.Errorf("graph has %d scenarios, want %d", len(graph.scenarios), len(test.specs))
+			}
+		})
+	}
+}
+
+func TestNewScenarioGraphExamples(t *testing.T) {
+	rows := []Scenario{
+		{Scenario: ExampleName("Create user", 1), Row: 1},
+		{Scenario: ExampleName("Create user", 2), Row: 2},
+		{Scenario: "List users", DependsOn: []string{"Create user"}},
+		{Scenario: "Get second user", DependsOn: []string{ExampleName("Create user", 2)}},
+	}
+	graph, err := newScenarioGraph(rows)
+	if err != nil {
+		t.Fatalf("newScenarioGraph() failed: %v", err)
+	}
+	if !reflect.DeepEqual(graph.upstream[2], []int{0, 1}) {
+		t.Errorf("a dependency on the scenario name waits for %v, want every row", graph.upstream[2])
+	}
+	if !reflect.DeepEqual(graph.upstream[3], []int{1}) {
+		t.Errorf("a dependency on one row waits for %v, want that row", graph.upstream[3])
+	}
+}
+
+// TestScenarioGraphRun checks against a live server that every scenario is
+// sent after its upstream scenarios and that the dependents of a failure are
+// skipped without being sent.
+func TestScenarioGraphRun(t *testing.T) {
+	var lock sync.Mutex
+	var sent []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		sent = append(sent, strings.TrimPrefix(r.URL.Path, "/"))
+		lock.Unlock()
+		if strings.HasPrefix(r.URL.Path, "/broken") {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		specs    []string
+		parallel int
+		want     map[string]string
+	}{
+		{name: "chain on one worker", specs: []string{"c: b", "b: a", "a"}, parallel: 1,
+			want: map[string]string{"a": "passed", "b": "passed", "c": "passed"}},
+		{name: "diamond on many workers", specs: []string{"d: b, c", "b: a", "c: a", "a"}, parallel: 4,
+			want: map[string]string{"a": "passed", "b": "passed", "c": "passed", "d": "passed"}},
+		{name: "skips propagate down the chain", specs: []string{"broken", "b: broken", "c: b", "other"}, parallel: 2,
+			want: map[string]string{"broken": "failed", "b": "skipped", "c": "skipped", "other": "passed"}},
+		{name: "one failed upstream is enough", specs: []string{"a", "broken", "d: a, broken"}, parallel: 2,
+			want: map[string]string{"a": "passed", "broken": "failed", "d": "skipped"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sent = nil
+			scenarios := graphOf(test.specs...)
+			for i := range scenarios {
+				scenarios[i].Method = "GET"
+				scenarios[i].Url = server.URL + "/" + scenarios[i].Scenario
+				scenarios[i].Status = 200
+			}
+			graph, err := newScenarioGraph(scenarios)
+			if err != nil {
+				t.Fatalf("newScenarioGraph() failed: %v", err)
+			}
+			finalScenarios := make(chan Scenario, len(scenarios))
+			graph.run(Config{}, test.parallel, finalScenarios)
+			close(finalScenarios)
+
+			got := map[string]string{}
+			for scenario := range finalScenarios {
+				got[scenario.Scenario] = scenario.ValidateOutcome.FinalStatus
+			}
+			if len(got) != len(test.want) {
+				t.Errorf("run() reported %v, want %v", got, test.want)
+			}
+			for name, want := range test.want {
+				if got[name] != want {
+					t.Errorf("scenario %s is %s, want %s", name, got[name], want)
+				}
+			}
+
+			order := map[string]int{}
+			for i, name := range sent {
+				order[name] = i
+			}
+			for _, scenario := range scenarios {
+				at, wasSent := order[scenario.Scenario]
+				if test.want[scenario.Scenario] == "skipped" {
+					if wasSent {
+						t.Errorf("skipped scenario %s was sent", scenario.Scenario)
+					}
+					continue
+				}
+				for _, upstream := range scenario.DependsOn {
+					if order[upstream] > at {
+						t.Errorf("%s was sent before its upstream %s: %v", scenario.Scenario, upstream, sent)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCheckDependencies(t *testing.T) {
+	if err := CheckDependencies(graphOf("b: a", "a")); err != nil {
+		t.Errorf("CheckDependencies() failed: %v", err)
+	}
+	if err := CheckDependencies(graphOf("a: b", "b: a")); err == nil {
+		t.Error("CheckDependencies() accepted a cycle")
+	}
+	if err := CheckDependencies(graphOf("a: gone")); err == nil {
+		t.Error("CheckDependencies() accepted an unknown upstream scenario")
+	}
+}
@@ -0,0 +1,145 @@
+package dash
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// compiledSchemas caches schemas by file path or inline source, since many
+// scenarios usually share the same schema.
+var compiledSchemas sync.Map
+
+// validateSchema checks the response body against the JSON Schema of a schema
+// validator. Every violation is reported on its own line with the json pointer
+// of the offending value; the validator counts as a single failure.
+func validateSchema(scenario *Scenario, validate Validate, body string, validateOutcome *ValidateOutcome) {
+	schema, name, err := loadSchema(validate.Schema, scenario.Dir)
+	if err != nil {
+		validateOutcome.Failed += 1
+		validateOutcome.Actual += fmt.Sprintf("Failed -- schema %s: %v\n", name, err)
+		return
+	}
+	violations, err := schemaViolations(schema, body)
+	if err != nil {
+		validateOutcome.Failed += 1
+		validateOutcome.Actual += fmt.Sprintf("Failed -- schema %s: %v\n", name, err)
+		return
+	}
+	if len(violations) == 0 {
+		validateOutcome.Passed += 1
+		validateOutcome.Actual += fmt.Sprintf("Passed -- schema %s\n", name)
+		return
+	}
+	validateOutcome.Failed += 1
+	for _, violation := range violations {
+		validateOutcome.Actual += fmt.Sprintf("Failed -- schema %s: %s\n", name, violation)
+	}
+}
+
+// schemaViolations validates a json document and lists the violations as
+// "pointer: message".
+func schemaViolations(schema *jsonschema.Schema, body string) ([]string, error) {
+	var document interface{}
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return nil, fmt.Errorf("response body is not json: %v", err)
+	}
+	err := schema.Validate(document)
+	if err == nil {
+		return nil, nil
+	}
+	validationErr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return nil, err
+	}
+	var violations []string
+	for _, leaf := range leafErrors(validationErr) {
+		pointer := leaf.InstanceLocation
+		if pointer == "" {
+			pointer = "/"
+		}
+		violations = append(violations, fmt.Sprintf("%s: %s", pointer, leaf.Message))
+	}
+	return violations, nil
+}
+
+// leafErrors drops the summary errors ("doesn't validate with ...") and keeps
+// the ones that point at an actual problem.
+func leafErrors(err *jsonschema.ValidationError) []*jsonschema.ValidationError {
+	if len(err.Causes) == 0 {
+		return []*jsonschema.ValidationError{err}
+	}
+	var leaves []*jsonschema.ValidationError
+	for _, cause := range err.Causes {
+		leaves = append(leaves, leafErrors(cause)...)
+	}
+	return leaves
+}
+
+// loadSchema compiles the schema of a validator. A string is a file path,
+// relative to the scenario file, unless it is inline json; a mapping is an
+// inline schema written in yaml.
+func loadSchema(source interface{}, dir string) (*jsonschema.Schema, string, error) {
+	switch schema := source.(type) {
+	case string:
+		trimmed := strings.TrimSpace(schema)
+		if strings.HasPrefix(trimmed, "{") {
+			compiled, err := compileInlineSchema(trimmed)
+			return compiled, "inline", err
+		}
+		path := trimmed
+		if !filepath.IsAbs(path) && dir != "" {
+			if _, err := os.Stat(filepath.Join(dir, path)); err == nil {
+				path = filepath.Join(dir, path)
+			}
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, trimmed, err
+		}
+		if cached, ok := compiledSchemas.Load(abs); ok {
+			return cached.(*jsonschema.Schema), trimmed, nil
+		}
+		compiled, err := jsonschema.NewCompiler().Compile(abs)
+		if err != nil {
+			return nil, trimmed, err
+		}
+		compiledSchemas.Store(abs, compiled)
+		return compiled, trimmed, nil
+	case map[string]interface{}:
+		out, err := json.Marshal(schema)
+		if err != nil {
+			return nil, "inline", err
+		}
+		compiled, err := compileInlineSchema(string(out))
+		return compiled, "inline", err
+	case nil:
+		return nil, "", fmt.Errorf("schema validator needs a schema file or an inline schema")
+	}
+	return nil, "inline", fmt.Errorf("unsupported schema value %T", source)
+}
+
+func compileInlineSchema(source string) (*jsonschema.Schema, error) {
+	if cached, ok := compiledSchemas.Load(source); ok {
+		return cached.(*jsonschema.Schema), nil
+	}
+	compiler := jsonschema.NewCompiler()
+	url := "inline://schema.json"
+	if err := compiler.AddResource(url, bytes.NewReader([]byte(source))); err != nil {
+		return nil, err
+	}
+	compiled, err := compiler.Compile(url)
+	if err != nil {
+		return nil, err
+	}
+	compiledSchemas.Store(source, compiled)
+	return compiled, nil
+}
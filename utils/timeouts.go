@@ -0,0 +1,87 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseDuration reads durations such as "500ms" or "2s"; a bare number is a
+// number of seconds, like Delay.
+func parseDuration(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration '%s', use e.g. 500ms, 2s or 1m", value)
+	}
+	return duration, nil
+}
+
+// requestContext bounds a request by the scenario timeout and the run. The
+// cancel func must only be called once the response body has been read.
+func (scenario *Scenario) requestContext() (context.Context, context.CancelFunc, error) {
+	timeout, err := parseDuration(scenario.Timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	if timeout <= 0 {
+		ctx, cancel := context.WithCancel(runContext)
+		return ctx, cancel, nil
+	}
+	ctx, cancel := context.WithTimeout(runContext, timeout)
+	return ctx, cancel, nil
+}
+
+// stepContext bounds an init, hook or teardown step by the timeout of the
+// config, the default of the scenarios.
+func stepContext(parent context.Context, config Config) (context.Context, context.CancelFunc, error) {
+	timeout, err := parseDuration(config.Timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	if timeout <= 0 {
+		ctx, cancel := context.WithCancel(parent)
+		return ctx, cancel, nil
+	}
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	return ctx, cancel, nil
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// checkResponseTime runs the max_response_time assertion of a scenario.
+func checkResponseTime(scenario *Scenario, validateOutcome *ValidateOutcome) {
+	if scenario.MaxResponseTime == "" || scenario.Response == nil {
+		return
+	}
+	limit, err := parseDuration(scenario.MaxResponseTime)
+	if err != nil {
+		validateOutcome.Failed += 1
+		validateOutcome.Actual += fmt.Sprintln("Failed -- max_response_time:", err)
+		return
+	}
+	took := time.Duration(scenario.Response.Time * float64(time.Second))
+	if took > limit {
+		validateOutcome.Failed += 1
+		validateOutcome.Actual += fmt.Sprintf("Failed -- response time %s exceeds max_response_time %s\n", took.Round(time.Millisecond), limit)
+		return
+	}
+	validateOutcome.Passed += 1
+	validateOutcome.Actual += fmt.Sprintf("Passed -- response time %s within max_response_time %s\n", took.Round(time.Millisecond), limit)
+}
@@ -11,48 +11,67 @@ import (
 	"path"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strconv"
 
 	"fmt"
 	"net/http"
 	"regexp"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/Knetic/govaluate"
 	"github.com/rs/xid"
 	_ "github.com/satori/go.uuid"
 	uuid "github.com/satori/go.uuid"
-	"github.com/segmentio/kafka-go"
 	"github.com/tidwall/gjson"
 )
 
 var (
-	mux                sync.Mutex
-	regex              = regexp.MustCompile("{{(.*?)}}")
-	kafkaScenarioQueue []kafka.Message
+	regex = regexp.MustCompile("{{(.*?)}}")
 )
 
 func Worker(scenario Scenario, config Config, finalScenarioChan chan Scenario) {
-	mux.Lock()
+	isolate(&scenario)
+	config = withExampleData(withRunVariables(config), scenario)
+	config, err := runHooks(&scenario, "before", scenario.Before, config)
 	getService(&scenario, config)
 	bodyConfigs(&scenario, config)
 	validatorConfigs(&scenario, config)
 	urlConfigs(&scenario, config)
-	switch scenario.Tag {
-	case "plain":
-		scenario.Request()
-		finalScenarioChan <- scenario
-	case "urlencoded":
-		scenario.UrlEncodedRequest()
-		finalScenarioChan <- scenario
-	default:
-		scenario.Request()
-		finalScenarioChan <- scenario
-	}
-	mux.Unlock()
+	authConfigs(&scenario, config)
+	if err != nil {
+		errorReporterWithReason(err, &scenario, "Before hook failed")
+	} else {
+		switch scenario.Tag {
+		case "plain":
+			scenario.Request()
+		case "urlencoded":
+			scenario.UrlEncodedRequest()
+		default:
+			scenario.Request()
+		}
+	}
+	runHooks(&scenario, "after", scenario.After, withRunVariables(config))
+	finalScenarioChan <- scenario
+}
 
+// isolate gives a scenario its own copies of the maps and slices it may share
+// with its replicas, so concurrent workers never write to the same memory.
+func isolate(scenario *Scenario) {
+	scenario.Headers = copyMap(scenario.Headers)
+	scenario.Params = copyMap(scenario.Params)
+	scenario.Validators = append(scenario.Validators[:0:0], scenario.Validators...)
+}
+func copyMap(src map[string]string) map[string]string {
+	if src == nil {
+		return nil
+	}
+	dst := make(map[string]string, len(src))
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
 }
 func getService(scenario *Scenario, config Config) {
 	if scenario.Method != "" {
@@ -66,22 +85,43 @@ func getService(scenario *Scenario, config Config) {
 			scenario.Domain = config.Metadata.Domain
 			scenario.Developer = i.Developer
 			scenario.Tester = i.Tester
-			scenario.Tag = i.Tag
-			scenario.Type = i.Type
-			scenario.Auth = i.Auth
+			if scenario.Tag == "" {
+				scenario.Tag = i.Tag
+			}
+			if scenario.Type == "" {
+				scenario.Type = i.Type
+			}
+			if scenario.Auth.Type == "" {
+				scenario.Auth = i.Auth
+			}
+			if scenario.Timeout == "" {
+				scenario.Timeout = i.Timeout
+			}
+			if !scenario.Retry.isSet() {
+				scenario.Retry = i.Retry
+			}
 
 			if i.Headers != nil && scenario.Headers != nil {
 				for k, v := range i.Headers {
 					scenario.Headers[k] = v
 				}
 			} else if i.Headers != nil && scenario.Headers == nil {
-				scenario.Headers = i.Headers
+				scenario.Headers = copyMap(i.Headers)
 			}
 		}
 	}
 
+	if scenario.Auth.Type == "" {
+		scenario.Auth = config.Auth
+	}
+	if scenario.Timeout == "" {
+		scenario.Timeout = config.Timeout
+	}
+	if !scenario.Retry.isSet() {
+		scenario.Retry = config.Retry
+	}
 	if scenario.Headers == nil && config.Headers != nil {
-		scenario.Headers = config.Headers
+		scenario.Headers = copyMap(config.Headers)
 	} else if config.Headers != nil && scenario.Headers != nil {
 		for k, v := range config.Headers {
 			scenario.Headers[k] = v
@@ -90,22 +130,7 @@ func getService(scenario *Scenario, config Config) {
 	for k, v := range scenario.Headers {
 		found := regex.FindAllString(v, -1)
 		if len(found) != 0 {
-			gotten := found[0]
-			replaced := trimmer(gotten)
-			if replaced == "uuid" {
-				guid := xid.New()
-				scenario.Headers[k] = guid.String()
-			}
-			if replaced == "guid" {
-				u := uuid.NewV4()
-				scenario.Headers[k] = u.String()
-			}
-			if replaced == "timestamp" {
-				scenario.Headers[k] = string(time.Now().Unix())
-			}
-			if config.Data[replaced] != "" {
-				scenario.Headers[k] = config.Data[replaced]
-			}
+			scenario.Headers[k] = recurse(found, v, config)
 		}
 	}
 }
@@ -119,14 +144,14 @@ func bodyConfigs(scenario *Scenario, config Config) {
 }
 func validatorConfigs(scenario *Scenario, config Config) {
 	for i, v := range scenario.Validators {
-		found := regex.FindAllString(v.Validate.Expected, -1)
-		if len(found) == 0 {
-			continue
+		found := regex.FindAllString(v.Validate.Extract, -1)
+		if len(found) != 0 {
+			scenario.Validators[i].Validate.Extract = recurse(found, v.Validate.Extract, config)
+		}
+		found = regex.FindAllString(v.Validate.Expected, -1)
+		if len(found) != 0 {
+			scenario.Validators[i].Validate.Expected = recurse(found, v.Validate.Expected, config)
 		}
-		gotten := found[0]
-		replaced := trimmer(gotten)
-		cfgData := templateVariables(replaced, config)
-		scenario.Validators[i].Validate.Expected = strings.ReplaceAll(v.Validate.Expected, gotten, cfgData)
 	}
 }
 func urlConfigs(scenario *Scenario, config Config) {
@@ -146,14 +171,13 @@ func urlConfigs(scenario *Scenario, config Config) {
 			if len(found) == 0 {
 				continue
 			}
-			gotten := found[0]
-			replaced := trimmer(gotten)
-			cfgData := templateVariables(replaced, config)
-			out := strings.ReplaceAll(v, gotten, cfgData)
-			scenario.Params[k] = out
+			scenario.Params[k] = recurse(found, v, config)
 		}
 	}
 }
+func authConfigs(scenario *Scenario, config Config) {
+	scenario.Auth = scenario.Auth.Resolve(config)
+}
 func recurse(found []string, str string, config Config) string {
 	i := len(found) - 1
 	if i < 0 {
@@ -195,34 +219,28 @@ func validator(scenario *Scenario, response *http.Response, body string) {
 		validateOutcome.Actual += fmt.Sprintln("Failed  -- Expected ", statusValidation)
 	}
 	for _, v := range scenario.Validators {
-		extract := gjson.Get(body, v.Validate.Extract)
-		comp := v.Validate.Comparator
-		expected := v.Validate.Expected
-		_statusValidation := fmt.Sprintf("'%s' %s '%s'", extract, comp, expected)
-		exp, err := govaluate.NewEvaluableExpression(_statusValidation)
-		if err != nil {
-			//errReport.ErrorDesc = err.Error()
-			//errReport.Reason = "Invalid comparator"
-			//scenario.ErrorReport = &errReport
-			continue
-		}
-		result, err := exp.Evaluate(nil)
-		if err != nil {
-			//errReport.ErrorDesc = err.Error()
-			//errReport.Reason = "Error performing comparison"
-			//scenario.ErrorReport = &errReport
-			//
+		var passed bool
+		var description string
+		switch strings.ToLower(v.Validate.Type) {
+		case "schema":
+			validateSchema(scenario, v.Validate, body, &validateOutcome)
 			continue
+		case "header", "cookie":
+			passed, description = validateResponseMeta(response, v.Validate)
+		default:
+			passed, description = compare(v.Validate, gjson.Get(body, v.Validate.Extract))
 		}
-
-		if result == true {
+		if passed {
 			validateOutcome.Passed += 1
-			validateOutcome.Actual += fmt.Sprintln("Passed -- Expected ", _statusValidation)
+			validateOutcome.Actual += fmt.Sprintln("Passed --", description)
 		} else {
 			validateOutcome.Failed += 1
-			validateOutcome.Actual += fmt.Sprintln("Failed -- Expected ", _statusValidation)
+			validateOutcome.Actual += fmt.Sprintln("Failed --", description)
 		}
 	}
+	checkContract(response, body, &validateOutcome)
+	checkResponseTime(scenario, &validateOutcome)
+	captureValues(scenario, body, &validateOutcome)
 	if validateOutcome.Failed > 0 {
 		validateOutcome.FinalStatus = "failed"
 		testReport.Outcome = "failed"
@@ -232,8 +250,29 @@ func validator(scenario *Scenario, response *http.Response, body string) {
 	}
 	scenario.ValidateOutcome = &validateOutcome
 }
+
+// captureValues stores the response values named in the scenario's capture
+// block so that later scenarios can use them as {{name}}.
+func captureValues(scenario *Scenario, body string, validateOutcome *ValidateOutcome) {
+	names := make([]string, 0, len(scenario.Capture))
+	for name := range scenario.Capture {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := scenario.Capture[name]
+		value := gjson.Get(body, path)
+		if !value.Exists() {
+			validateOutcome.Failed += 1
+			validateOutcome.Actual += fmt.Sprintf("Failed -- Capture %s: nothing found at '%s'\n", name, path)
+			continue
+		}
+		runVariables.Set(name, value.String())
+		validateOutcome.Actual += fmt.Sprintf("Captured -- %s = '%s'\n", name, value.String())
+	}
+}
 func templateVariables(rep string, config Config) string {
-	cfg := config.Data[rep]
+	cfg, ok := config.Data[rep]
 	if rep == "guid" {
 		guid := xid.New()
 		rep = guid.String()
@@ -243,7 +282,7 @@ func templateVariables(rep string, config Config) string {
 	} else if rep == "uuid" {
 		u := uuid.NewV4()
 		return u.String()
-	} else if cfg != "" {
+	} else if ok {
 		return cfg
 	}
 	return fmt.Sprintf("{{%s}}", rep)
@@ -264,6 +303,7 @@ func GetFinalReport(scenario Scenario) ReportTemplate {
 	var reportTemplate ReportTemplate
 	jsonHeaders, _ := json.Marshal(scenario.Headers)
 	if scenario.ErrorOutcome != nil {
+		reportTemplate.ErrorReason = scenario.ErrorOutcome.Reason
 		reportTemplate.ErrorDescription = scenario.ErrorOutcome.ErrorDesc
 	}
 	if scenario.ValidateOutcome != nil {
@@ -271,6 +311,7 @@ func GetFinalReport(scenario Scenario) ReportTemplate {
 		reportTemplate.FailedCount = scenario.ValidateOutcome.Failed
 		reportTemplate.ValidationDescription = scenario.ValidateOutcome.Actual
 		reportTemplate.FinalTestStatus = scenario.ValidateOutcome.FinalStatus
+		reportTemplate.Warnings = strings.Join(scenario.ValidateOutcome.Warnings, "\n")
 	} else {
 		reportTemplate.FinalTestStatus = "error"
 	}
@@ -283,7 +324,10 @@ func GetFinalReport(scenario Scenario) ReportTemplate {
 		reportTemplate.ResponseCode = 0
 		reportTemplate.ResponseTime = 0
 	}
+	reportTemplate.Attempts = scenario.Attempts
+	reportTemplate.Polls = scenario.Polls
 
+	reportTemplate.Phase = "test"
 	reportTemplate.Scenario = scenario.Scenario
 	reportTemplate.Service = scenario.Service
 	reportTemplate.Tag = scenario.Tag
@@ -293,6 +337,7 @@ func GetFinalReport(scenario Scenario) ReportTemplate {
 	reportTemplate.Method = scenario.Method
 	reportTemplate.Severity = scenario.Severity
 	reportTemplate.Priority = scenario.Priority
+	reportTemplate.Labels = strings.Join(scenario.Labels, ",")
 	reportTemplate.Body = strings.Replace(scenario.Body, "\"", "'", -1)
 	reportTemplate.Headers = strings.Replace(string(jsonHeaders), "\"", "'", -1)
 	reportTemplate.Project = scenario.Project
@@ -304,8 +349,7 @@ func GetFinalReport(scenario Scenario) ReportTemplate {
 	reportTemplate.Developer = scenario.Developer
 	reportTemplate.Tester = scenario.Tester
 	reportTemplate.Domain = scenario.Domain
-	scenarioStream, _ := json.Marshal(&scenario)
-	kafkaScenarioQueue = append(kafkaScenarioQueue, kafka.Message{Value: scenarioStream})
+	reportTemplate.Curl = ToCurl(scenario)
 	return reportTemplate
 }
 func RootDir() string {
@@ -332,41 +376,85 @@ func RootDir() string {
 	return filepath.Dir(d)
 
 }
-func Commander(totalScenarios int, finalScenarios chan Scenario, sessionID string, reportOut *string, verboseMsg *string, scenarios []Scenario, config Config) {
-	printerChan := make(chan ReportTemplate, totalScenarios)
-	csvChan := make(chan ReportTemplate, totalScenarios)
-
+func Commander(totalScenarios int, finalScenarios chan Scenario, sessionID string, reportOut *string, verboseMsg *string, scenarios []Scenario, config Config, setupReports []ReportTemplate, options RunOptions) RunSummary {
 	var reports []ReportTemplate
-	for i := 0; i < totalScenarios; i++ {
-		go Worker(scenarios[i], config, finalScenarios)
+	var summary RunSummary
+	stream := newKafkaReporter(config, sessionID)
+	defer stream.close()
+	for _, reportTemplate := range setupReports {
+		reportTemplate.RunID = sessionID
+		reportTemplate.ExecutionTime = time.Now().Format("2006-01-02 15:04:05")
+		reports = append(reports, reportTemplate)
+		stream.publish(reportTemplate, nil)
+	}
+	if options.Parallel < 1 {
+		options.Parallel = 1
+	}
+	graph, err := newScenarioGraph(scenarios)
+	if err != nil {
+		ExitConfigError("Invalid scenario dependencies: Cause: ", err)
 	}
+	limit := options.failureLimit()
+	go graph.run(config, options.Parallel, finalScenarios)
 	for a := 1; a <= totalScenarios; a++ {
 		scenario := <-finalScenarios
 		scenario.RunID = sessionID
 		scenario.ExecutionTime = time.Now().Format("2006-01-02 15:04:05")
 		reportTemplate := GetFinalReport(scenario)
 		reports = append(reports, reportTemplate)
-		printerChan <- reportTemplate
-		csvChan <- reportTemplate
-		reportStream, _ := json.Marshal(&reportTemplate)
-		kafkaScenarioQueue = append(kafkaScenarioQueue, kafka.Message{Value: reportStream})
+		stream.publish(reportTemplate, &scenario)
+		summary.add(reportTemplate)
+		if reportTemplate.Warnings != "" {
+			log.Warnf("%s: %s", reportTemplate.Scenario, strings.ReplaceAll(reportTemplate.Warnings, "\n", "; "))
+		}
+		if limit > 0 && summary.Failed+summary.Errors >= limit && !runCancelled() {
+			log.Warnf("Stopping the run after %d failed scenarios.", summary.Failed+summary.Errors)
+			cancelRun()
+		}
+		for _, hookReport := range scenario.HookReports {
+			hookReport.RunID = sessionID
+			hookReport.ExecutionTime = scenario.ExecutionTime
+			reports = append(reports, hookReport)
+			stream.publish(hookReport, nil)
+		}
 		if *verboseMsg != "" {
 			out, _ := json.Marshal(&reportTemplate)
 			fmt.Println(string(out))
+			fmt.Println(reportTemplate.Curl)
 		}
 	}
+	close(finalScenarios)
+	summary.Interrupted = runInterrupted()
+	for _, reportTemplate := range RunTeardown(config) {
+		reportTemplate.RunID = sessionID
+		reportTemplate.ExecutionTime = time.Now().Format("2006-01-02 15:04:05")
+		reports = append(reports, reportTemplate)
+		stream.publish(reportTemplate, nil)
+	}
+	printerChan := make(chan ReportTemplate, len(reports))
+	csvChan := make(chan ReportTemplate, len(reports))
+	for _, reportTemplate := range reports {
+		printerChan <- reportTemplate
+		csvChan <- reportTemplate
+	}
 	close(csvChan)
 	close(printerChan)
-	close(finalScenarios)
 
-	switch *reportOut {
-	case "csv":
-		SaveToCSV(csvChan)
-	case "json":
-		saveToJson(reports, sessionID)
-	case "all":
-		SaveToCSV(csvChan)
-		saveToJson(reports, sessionID)
+	for _, format := range reportFormats(*reportOut) {
+		switch format {
+		case "csv":
+			SaveToCSV(csvChan)
+		case "json":
+			saveToJson(reports, sessionID)
+		case "junit":
+			saveToJUnit(reports, sessionID)
+		case "html":
+			saveToHTML(reports, sessionID, config.Metadata)
+		case "har":
+			saveToHAR(sessionID)
+		default:
+			log.Warnf("Unsupported output format %s, ignored.", format)
+		}
 	}
 
 	var data [][]string
@@ -380,6 +468,32 @@ func Commander(totalScenarios int, finalScenarios chan Scenario, sessionID strin
 	table.SetCenterSeparator("|")
 	table.AppendBulk(data)
 	table.Render()
+	return summary
+}
+
+// reportFormats splits a comma separated -o value; all selects every format.
+func reportFormats(reportOut string) []string {
+	var formats []string
+	seen := map[string]bool{}
+	for _, format := range strings.Split(reportOut, ",") {
+		format = strings.ToLower(strings.TrimSpace(format))
+		var expanded []string
+		switch format {
+		case "":
+			continue
+		case "all":
+			expanded = []string{"csv", "json", "junit", "html"}
+		default:
+			expanded = []string{format}
+		}
+		for _, f := range expanded {
+			if !seen[f] {
+				seen[f] = true
+				formats = append(formats, f)
+			}
+		}
+	}
+	return formats
 }
 
 func SaveToCSV(scenarioChan chan ReportTemplate) {
@@ -0,0 +1,69 @@
+package dash
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetService(t *testing.T) {
+	config := Config{
+		Metadata: Metadata{Project: "matrix", Environment: "staging"},
+		Services: []Services{{
+			Name:      "users",
+			Tag:       "plain",
+			Type:      "soap",
+			Timeout:   "5s",
+			Auth:      Auth{Type: "bearer", Token: "service-token"},
+			Headers:   map[string]string{"X-Service": "users"},
+			Developer: "jane",
+		}},
+	}
+	tests := []struct {
+		name     string
+		scenario Scenario
+		want     Scenario
+	}{
+		{
+			name:     "scenario without its own values takes the service ones",
+			scenario: Scenario{Service: "users", Method: "post"},
+			want: Scenario{
+				Service: "users", Method: "POST", Tag: "plain", Type: "soap", Timeout: "5s",
+				Auth:    Auth{Type: "bearer", Token: "service-token"},
+				Headers: map[string]string{"X-Service": "users"},
+			},
+		},
+		{
+			name: "scenario values win over the service ones",
+			scenario: Scenario{
+				Service: "users", Method: "POST", Tag: "urlencoded", Type: "rest", Timeout: "1s",
+				Auth: Auth{Type: "basic", Values: "neo:zion"},
+			},
+			want: Scenario{
+				Service: "users", Method: "POST", Tag: "urlencoded", Type: "rest", Timeout: "1s",
+				Auth:    Auth{Type: "basic", Values: "neo:zion"},
+				Headers: map[string]string{"X-Service": "users"},
+			},
+		},
+		{
+			name:     "unknown service leaves the scenario alone",
+			scenario: Scenario{Service: "orders", Method: "get", Tag: "urlencoded"},
+			want:     Scenario{Service: "orders", Method: "GET", Tag: "urlencoded"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scenario := test.scenario
+			getService(&scenario, config)
+			got := Scenario{
+				Service: scenario.Service, Method: scenario.Method, Tag: scenario.Tag, Type: scenario.Type,
+				Timeout: scenario.Timeout, Auth: scenario.Auth, Headers: scenario.Headers,
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("getService() = %+v, want %+v", got, test.want)
+			}
+			if test.scenario.Service == "users" && (scenario.Project != "matrix" || scenario.Developer != "jane") {
+				t.Errorf("getService() did not copy the metadata: %+v", scenario)
+			}
+		})
+	}
+}